@@ -0,0 +1,245 @@
+package cryptopay
+
+import (
+	"encoding/json"
+	"fmt"
+	"math/big"
+	"strconv"
+	"strings"
+)
+
+// Amount is exact decimal number with arbitrary precision.
+// It is used for all money values of API instead of float64.
+//
+// Amount is immutable, all arithmetic methods return new value.
+// Zero value of Amount is 0.
+type Amount struct {
+	// value is unscaled value of number.
+	value *big.Int
+	// scale is count of digits after decimal point, number = value * 10^-scale.
+	scale int32
+}
+
+// NewAmount returns Amount equals value * 10^-scale.
+// For example, NewAmount(314, 2) is 3.14.
+func NewAmount(value int64, scale int32) Amount {
+	if scale < 0 {
+		return Amount{value: new(big.Int).Mul(big.NewInt(value), pow10(-scale))}
+	}
+	return Amount{value: big.NewInt(value), scale: scale}
+}
+
+// maxAmountScale limits exponent and count of decimal places of parsed amount.
+// It's far above precision of any asset, but keeps huge exponents from exhausting CPU and memory.
+const maxAmountScale = 1000
+
+// ParseAmount parses decimal number from string.
+// Supports optional sign, fractional part and exponent, for example "-3.14" or "1e-8".
+// Exponent and count of decimal places are limited by 1000.
+func ParseAmount(s string) (Amount, error) {
+	mantissa, exp := s, int64(0)
+	if i := strings.IndexAny(s, "eE"); i != -1 {
+		var err error
+		if exp, err = strconv.ParseInt(s[i+1:], 10, 32); err != nil {
+			return Amount{}, fmt.Errorf("crypto-pay: invalid amount %q", s)
+		}
+		if exp > maxAmountScale || exp < -maxAmountScale {
+			return Amount{}, fmt.Errorf("crypto-pay: exponent of amount %q is out of range", s)
+		}
+		mantissa = s[:i]
+	}
+	intPart, fracPart := mantissa, ""
+	if i := strings.IndexByte(mantissa, '.'); i != -1 {
+		intPart, fracPart = mantissa[:i], mantissa[i+1:]
+	}
+	digits := intPart + fracPart
+	if len(digits) > 0 && (digits[0] == '-' || digits[0] == '+') && len(intPart) > 0 {
+		digits = digits[1:]
+	}
+	if digits == "" || strings.IndexFunc(digits, func(r rune) bool { return r < '0' || r > '9' }) != -1 {
+		return Amount{}, fmt.Errorf("crypto-pay: invalid amount %q", s)
+	}
+	value, _ := new(big.Int).SetString(digits, 10)
+	if strings.HasPrefix(s, "-") {
+		value.Neg(value)
+	}
+	scale := int64(len(fracPart)) - exp
+	if scale > maxAmountScale || scale < -maxAmountScale {
+		return Amount{}, fmt.Errorf("crypto-pay: scale of amount %q is out of range", s)
+	}
+	if scale < 0 {
+		return Amount{value: value.Mul(value, pow10(int32(-scale)))}, nil
+	}
+	return Amount{value: value, scale: int32(scale)}, nil
+}
+
+// MustParseAmount is like ParseAmount but panics if the string can't be parsed.
+func MustParseAmount(s string) Amount {
+	a, err := ParseAmount(s)
+	if err != nil {
+		panic(err)
+	}
+	return a
+}
+
+// String returns decimal representation of number without exponent.
+func (a Amount) String() string {
+	v := a.int()
+	digits := new(big.Int).Abs(v).String()
+	if a.scale > 0 {
+		if pad := int(a.scale) - len(digits) + 1; pad > 0 {
+			digits = strings.Repeat("0", pad) + digits
+		}
+		digits = digits[:len(digits)-int(a.scale)] + "." + digits[len(digits)-int(a.scale):]
+	}
+	if v.Sign() < 0 {
+		return "-" + digits
+	}
+	return digits
+}
+
+// Float64 returns the nearest float64 value for number.
+// Use it only for displaying, float64 isn't exact.
+func (a Amount) Float64() float64 {
+	f, _ := strconv.ParseFloat(a.String(), 64)
+	return f
+}
+
+// Add returns a + b.
+func (a Amount) Add(b Amount) Amount {
+	scale := maxScale(a, b)
+	return Amount{value: new(big.Int).Add(a.rescale(scale), b.rescale(scale)), scale: scale}
+}
+
+// Sub returns a - b.
+func (a Amount) Sub(b Amount) Amount {
+	scale := maxScale(a, b)
+	return Amount{value: new(big.Int).Sub(a.rescale(scale), b.rescale(scale)), scale: scale}
+}
+
+// Mul returns a * b.
+func (a Amount) Mul(b Amount) Amount {
+	return Amount{value: new(big.Int).Mul(a.int(), b.int()), scale: a.scale + b.scale}
+}
+
+// Neg returns -a.
+func (a Amount) Neg() Amount {
+	return Amount{value: new(big.Int).Neg(a.int()), scale: a.scale}
+}
+
+// Cmp compares a and b and returns:
+//
+//	-1 if a <  b
+//	 0 if a == b
+//	+1 if a >  b
+func (a Amount) Cmp(b Amount) int {
+	scale := maxScale(a, b)
+	return a.rescale(scale).Cmp(b.rescale(scale))
+}
+
+// Equal reports whether a and b are equal numbers, regardless of the scale.
+func (a Amount) Equal(b Amount) bool { return a.Cmp(b) == 0 }
+
+// Sign returns -1 if a < 0, 0 if a == 0 and +1 if a > 0.
+func (a Amount) Sign() int { return a.int().Sign() }
+
+// IsZero reports whether a == 0.
+func (a Amount) IsZero() bool { return a.Sign() == 0 }
+
+// Round returns number rounded half away from zero to given count of digits after decimal point.
+// If number already has fewer digits, it is returned as is.
+func (a Amount) Round(places int32) Amount {
+	return a.round(places, true)
+}
+
+// Truncate returns number truncated (rounded towards zero) to given count of digits after decimal point.
+// If number already has fewer digits, it is returned as is.
+func (a Amount) Truncate(places int32) Amount {
+	return a.round(places, false)
+}
+
+// RoundTo returns number rounded to precision of the currency (CurrencyInfo.Decimals).
+func (a Amount) RoundTo(currency CurrencyInfo) Amount {
+	return a.Round(int32(currency.Decimals))
+}
+
+// MarshalJSON implements json.Marshaler. Amount is encoded as JSON string, like API does.
+func (a Amount) MarshalJSON() ([]byte, error) {
+	return json.Marshal(a.String())
+}
+
+// UnmarshalJSON implements json.Unmarshaler. Accepts JSON strings and numbers.
+// Empty string and null are decoded as zero.
+func (a *Amount) UnmarshalJSON(data []byte) error {
+	s := string(data)
+	if s == "null" {
+		*a = Amount{}
+		return nil
+	}
+	if strings.HasPrefix(s, `"`) {
+		if err := json.Unmarshal(data, &s); err != nil {
+			return err
+		}
+		if s == "" {
+			*a = Amount{}
+			return nil
+		}
+	}
+	parsed, err := ParseAmount(s)
+	if err != nil {
+		return err
+	}
+	*a = parsed
+	return nil
+}
+
+// int returns unscaled value, nil value is 0.
+func (a Amount) int() *big.Int {
+	if a.value == nil {
+		return new(big.Int)
+	}
+	return a.value
+}
+
+// rescale returns unscaled value for given scale. Scale must be greater or equal to a.scale.
+func (a Amount) rescale(scale int32) *big.Int {
+	v := new(big.Int).Set(a.int())
+	if scale > a.scale {
+		v.Mul(v, pow10(scale-a.scale))
+	}
+	return v
+}
+
+func (a Amount) round(places int32, halfUp bool) Amount {
+	if places < 0 {
+		places = 0
+	}
+	if a.scale <= places {
+		return a
+	}
+	divisor := pow10(a.scale - places)
+	q, r := new(big.Int).QuoRem(a.int(), divisor, new(big.Int))
+	if halfUp && new(big.Int).Lsh(r.Abs(r), 1).Cmp(divisor) >= 0 {
+		q.Add(q, big.NewInt(int64(a.int().Sign())))
+	}
+	return Amount{value: q, scale: places}
+}
+
+// amountParam formats amount for request params. Zero amount is treated as unset.
+func amountParam(a Amount) string {
+	if a.IsZero() {
+		return ""
+	}
+	return a.String()
+}
+
+func maxScale(a, b Amount) int32 {
+	if a.scale > b.scale {
+		return a.scale
+	}
+	return b.scale
+}
+
+func pow10(n int32) *big.Int {
+	return new(big.Int).Exp(big.NewInt(10), big.NewInt(int64(n)), nil)
+}
@@ -0,0 +1,132 @@
+package cryptopay
+
+import (
+	"encoding/json"
+	"strings"
+	"testing"
+)
+
+func TestParseAmount(t *testing.T) {
+	var cases = []struct{ input, expected string }{
+		{input: "0", expected: "0"},
+		{input: "3.14", expected: "3.14"},
+		{input: "-3.14", expected: "-3.14"},
+		{input: "+1.50", expected: "1.50"},
+		{input: ".5", expected: "0.5"},
+		{input: "-.5", expected: "-0.5"},
+		{input: "0.00000001", expected: "0.00000001"},
+		{input: "1e-8", expected: "0.00000001"},
+		{input: "1.5E3", expected: "1500"},
+		{input: "123456789012345678901234567890.123456789", expected: "123456789012345678901234567890.123456789"},
+	}
+	for _, tc := range cases {
+		t.Run(tc.input, func(t *testing.T) {
+			a, err := ParseAmount(tc.input)
+			if err != nil {
+				t.Fatal(err)
+			}
+			if a.String() != tc.expected {
+				t.Errorf(`expected "%s", but got "%s"`, tc.expected, a.String())
+			}
+		})
+	}
+	for _, input := range []string{"", "-", ".", "1.2.3", "abc", "1,5", "--1", "1e", "1e1.5", "0x10",
+		"1e-2147483648", "1e50000000", "1e1001", "0." + strings.Repeat("0", 1001) + "1"} {
+		if _, err := ParseAmount(input); err == nil {
+			t.Errorf("parsed invalid amount %.20q", input)
+		}
+	}
+	var a Amount
+	if err := json.Unmarshal([]byte("1e50000000"), &a); err == nil {
+		t.Error("unmarshalled amount with huge exponent")
+	}
+	if a, err := ParseAmount("1e1000"); err != nil || a.Sign() <= 0 {
+		t.Errorf("1e1000 not parsed: %v", err)
+	}
+}
+
+func TestAmount_Arithmetic(t *testing.T) {
+	a, b := MustParseAmount("0.1"), MustParseAmount("0.2")
+	if sum := a.Add(b); sum.String() != "0.3" {
+		t.Errorf("0.1 + 0.2 = %s", sum)
+	}
+	if diff := a.Sub(MustParseAmount("1.25")); diff.String() != "-1.15" {
+		t.Errorf("0.1 - 1.25 = %s", diff)
+	}
+	if prod := MustParseAmount("1.5").Mul(MustParseAmount("-2.25")); prod.String() != "-3.375" {
+		t.Errorf("1.5 * -2.25 = %s", prod)
+	}
+	if neg := b.Neg(); neg.Sign() != -1 || neg.String() != "-0.2" {
+		t.Errorf("-(0.2) = %s", neg)
+	}
+	var zero Amount
+	if !zero.IsZero() || zero.String() != "0" || !zero.Add(a).Equal(a) {
+		t.Error("invalid zero value")
+	}
+}
+
+func TestAmount_Cmp(t *testing.T) {
+	if MustParseAmount("1.50").Cmp(NewAmount(15, 1)) != 0 {
+		t.Error("1.50 != 1.5")
+	}
+	if MustParseAmount("1.49").Cmp(NewAmount(15, 1)) != -1 {
+		t.Error("1.49 >= 1.5")
+	}
+	if NewAmount(2, -2).Cmp(MustParseAmount("199.99")) != 1 {
+		t.Error("200 <= 199.99")
+	}
+}
+
+func TestAmount_Round(t *testing.T) {
+	var cases = []struct {
+		input            string
+		places           int32
+		rounded, truncat string
+	}{
+		{input: "1.005", places: 2, rounded: "1.01", truncat: "1.00"},
+		{input: "-1.005", places: 2, rounded: "-1.01", truncat: "-1.00"},
+		{input: "2.4999", places: 0, rounded: "2", truncat: "2"},
+		{input: "2.5", places: 0, rounded: "3", truncat: "2"},
+		{input: "1.5", places: 8, rounded: "1.5", truncat: "1.5"},
+	}
+	for _, tc := range cases {
+		t.Run(tc.input, func(t *testing.T) {
+			a := MustParseAmount(tc.input)
+			if got := a.Round(tc.places).String(); got != tc.rounded {
+				t.Errorf("Round(%d) = %s, expected %s", tc.places, got, tc.rounded)
+			}
+			if got := a.Truncate(tc.places).String(); got != tc.truncat {
+				t.Errorf("Truncate(%d) = %s, expected %s", tc.places, got, tc.truncat)
+			}
+		})
+	}
+	if got := MustParseAmount("0.123456789").RoundTo(CurrencyInfo{Code: BTC, Decimals: 8}); got.String() != "0.12345679" {
+		t.Errorf("RoundTo(BTC) = %s", got)
+	}
+}
+
+func TestAmount_JSON(t *testing.T) {
+	var v struct {
+		String Amount `json:"string"`
+		Number Amount `json:"number"`
+		Empty  Amount `json:"empty"`
+		Null   Amount `json:"null"`
+	}
+	data := []byte(`{"string":"40000.12","number":12.5,"empty":"","null":null}`)
+	if err := json.Unmarshal(data, &v); err != nil {
+		t.Fatal(err)
+	}
+	if v.String.String() != "40000.12" || v.Number.String() != "12.5" || !v.Empty.IsZero() || !v.Null.IsZero() {
+		t.Errorf("invalid decoding %+v", v)
+	}
+	encoded, err := json.Marshal(v)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if string(encoded) != `{"string":"40000.12","number":"12.5","empty":"0","null":"0"}` {
+		t.Errorf("invalid encoding %s", encoded)
+	}
+	if err := json.Unmarshal([]byte(`{"string":"NaN"}`), &v); err == nil {
+		t.Error("decoded invalid amount")
+	}
+}
@@ -1,12 +1,17 @@
 package cryptopay
 
 import (
+	"bytes"
+	"context"
 	"encoding/json"
+	"errors"
 	"fmt"
+	"io"
 	"net/http"
 	"net/url"
 	"strconv"
 	"strings"
+	"time"
 )
 
 // Aliases for officials hosts
@@ -16,51 +21,140 @@ const (
 	TestNetHost = "https://testnet-pay.crypt.bot"
 )
 
+// Names of API methods. They are keys of RateLimitSettings.Methods.
 const (
-	emptyQuery             = ""
-	getMeMethod            = "getMe"
-	createInvoiceMethod    = "createInvoice"
-	transferMethod         = "transfer"
-	getInvoicesMethod      = "getInvoices"
-	getBalanceMethod       = "getBalance"
-	getExchangeRatesMethod = "getExchangeRates"
-	getCurrenciesMethod    = "getCurrencies"
-	headerTokenName        = "Crypto-Pay-API-Token"
+	MethodGetMe            = "getMe"
+	MethodCreateInvoice    = "createInvoice"
+	MethodDeleteInvoice    = "deleteInvoice"
+	MethodTransfer         = "transfer"
+	MethodGetInvoices      = "getInvoices"
+	MethodGetTransfers     = "getTransfers"
+	MethodGetBalance       = "getBalance"
+	MethodGetExchangeRates = "getExchangeRates"
+	MethodGetCurrencies    = "getCurrencies"
+	MethodCreateCheck      = "createCheck"
+	MethodDeleteCheck      = "deleteCheck"
+	MethodGetChecks        = "getChecks"
+	MethodGetStats         = "getStats"
+)
+
+const (
+	emptyQuery      = ""
+	headerTokenName = "Crypto-Pay-API-Token"
 )
 
 type (
 	// CreateInvoiceOptions for `createInvoice` api method.
 	CreateInvoiceOptions struct {
-		Asset          Asset      // Currency code.
-		Amount         string     // Amount of the invoice in float.
-		Description    string     // Optional. Description for the invoice. User will see this description when they pay the invoice. Up to 1024 characters.
-		HiddenMessage  string     // Optional. Text of the message that will be shown to a user after the invoice is paid. Up to 2o48 characters.
-		PaidButtonName PaidButton // Optional. Name of the button that will be shown to a user after the invoice is paid.
-		PaidButtonUrl  string     // Optional. Required if PaidButtonName is used. URL to be opened when the button is pressed. You can set any success link (for example, a link to your bot). Starts with https or http.
-		Payload        string     // Optional. Any data you want to attach to the invoice (for example, user ID, payment ID, ect). Up to 4kb.
-		AllowComments  bool       // Optional. Allow a user to add a comment to the payment. Default is true.
-		AllowAnonymous bool       // Optional. Allow a user to pay the invoice anonymously. Default is true.
-		ExpiresIn      int        // Optional. You can set a payment time limit for the invoice in seconds. Values between 1-2678400 are accepted
+		CurrencyType   CurrencyType `json:"currency_type,omitempty"`   // Optional. Type of the price, can be "crypto" or "fiat". Defaults to crypto.
+		Asset          Asset        `json:"asset,omitempty"`           // Required if CurrencyType is "crypto". Currency code.
+		Fiat           Fiat         `json:"fiat,omitempty"`            // Required if CurrencyType is "fiat". Fiat currency code.
+		AcceptedAssets []Asset      `json:"accepted_assets,omitempty"` // Optional. List of assets which can be used to pay the invoice. Available only if CurrencyType is "fiat". Defaults to all currencies.
+		Amount         Amount       `json:"amount"`                    // Amount of the invoice.
+		Description    string       `json:"description,omitempty"`     // Optional. Description for the invoice. User will see this description when they pay the invoice. Up to 1024 characters.
+		HiddenMessage  string       `json:"hidden_message,omitempty"`  // Optional. Text of the message that will be shown to a user after the invoice is paid. Up to 2o48 characters.
+		PaidButtonName PaidButton   `json:"paid_btn_name,omitempty"`   // Optional. Name of the button that will be shown to a user after the invoice is paid.
+		PaidButtonUrl  string       `json:"paid_btn_url,omitempty"`    // Optional. Required if PaidButtonName is used. URL to be opened when the button is pressed. You can set any success link (for example, a link to your bot). Starts with https or http.
+		Payload        string       `json:"payload,omitempty"`         // Optional. Any data you want to attach to the invoice (for example, user ID, payment ID, ect). Up to 4kb.
+		AllowComments  *bool        `json:"allow_comments,omitempty"`  // Optional. Allow a user to add a comment to the payment. Default is true. Use Bool for set value.
+		AllowAnonymous *bool        `json:"allow_anonymous,omitempty"` // Optional. Allow a user to pay the invoice anonymously. Default is true. Use Bool for set value.
+		ExpiresIn      int          `json:"expires_in,omitempty"`      // Optional. You can set a payment time limit for the invoice in seconds. Values between 1-2678400 are accepted
+		SwapTo         Asset        `json:"swap_to,omitempty"`         // Optional. Asset to which the paid amount will be automatically swapped.
 	}
 	// DoTransferOptions for `transfer` (DoTransfer) api method.
 	DoTransferOptions struct {
-		UserId                  int    // Telegram user ID. User must have previously used @CryptoBot (@CryptoTestnetBot for testnet).
-		Asset                   Asset  // Currency code.
-		Amount                  string // Amount of the invoice in float.
-		SpendId                 string // Unique ID to make your request idempotent and ensure that only one of the transfers with the same spend_id will be accepted by Crypto Pay API. More https://telegra.ph/Crypto-Pay-API-11-25#transfer
-		Comment                 string // Optional. Comment for the transfer. Users will see this comment when they receive a notification about the transfer. Up to 1024 symbols.
-		DisableSendNotification bool   // Optional. Pass true if the user should not receive a notification about the transfer. Default is false.
+		UserId                  int    `json:"user_id"`                             // Telegram user ID. User must have previously used @CryptoBot (@CryptoTestnetBot for testnet).
+		Asset                   Asset  `json:"asset,omitempty"`                     // Currency code.
+		Amount                  Amount `json:"amount"`                              // Amount of the transfer.
+		SpendId                 string `json:"spend_id,omitempty"`                  // Unique ID to make your request idempotent and ensure that only one of the transfers with the same spend_id will be accepted by Crypto Pay API. More https://telegra.ph/Crypto-Pay-API-11-25#transfer
+		Comment                 string `json:"comment,omitempty"`                   // Optional. Comment for the transfer. Users will see this comment when they receive a notification about the transfer. Up to 1024 symbols.
+		DisableSendNotification *bool  `json:"disable_send_notification,omitempty"` // Optional. Pass true if the user should not receive a notification about the transfer. Default is false. Use Bool for set value.
 	}
 	// GetInvoicesOptions for `getInvoices` api method.
 	GetInvoicesOptions struct {
-		Asset      Asset         // Currency code.
-		InvoiceIds []string      // Optional. Invoice IDs
-		Status     InvoiceStatus // Optional. Status of invoices to be returned. Defaults to all statuses.
-		Offset     int           // Optional. Offset needed to return a specific subset of invoices. Default is 0.
-		Count      int           // Optional. Number of invoices to be returned. Values between 1-1000 are accepted. Defaults to 100.
+		Asset      Asset         `json:"asset,omitempty"`       // Currency code.
+		InvoiceIds []string      `json:"invoice_ids,omitempty"` // Optional. Invoice IDs
+		Status     InvoiceStatus `json:"status,omitempty"`      // Optional. Status of invoices to be returned. Defaults to all statuses.
+		Offset     int           `json:"offset,omitempty"`      // Optional. Offset needed to return a specific subset of invoices. Default is 0.
+		Count      int           `json:"count,omitempty"`       // Optional. Number of invoices to be returned. Values between 1-1000 are accepted. Defaults to 100.
+	}
+	// GetTransfersOptions for `getTransfers` api method.
+	GetTransfersOptions struct {
+		Asset       Asset    `json:"asset,omitempty"`        // Optional. Currency code. Defaults to all currencies.
+		TransferIds []string `json:"transfer_ids,omitempty"` // Optional. Transfer IDs.
+		SpendId     string   `json:"spend_id,omitempty"`     // Optional. Unique UTF-8 transfer string.
+		Offset      int      `json:"offset,omitempty"`       // Optional. Offset needed to return a specific subset of transfers. Default is 0.
+		Count       int      `json:"count,omitempty"`        // Optional. Number of transfers to be returned. Values between 1-1000 are accepted. Defaults to 100.
+	}
+	// CreateCheckOptions for `createCheck` api method.
+	CreateCheckOptions struct {
+		Asset         Asset  `json:"asset,omitempty"`           // Currency code.
+		Amount        Amount `json:"amount"`                    // Amount of the check.
+		PinToUserId   int    `json:"pin_to_user_id,omitempty"`  // Optional. ID of the user who will be able to activate the check.
+		PinToUsername string `json:"pin_to_username,omitempty"` // Optional. A user with the specified username will be able to activate the check.
+	}
+	// GetChecksOptions for `getChecks` api method.
+	GetChecksOptions struct {
+		Asset    Asset       `json:"asset,omitempty"`     // Optional. Currency code. Defaults to all currencies.
+		CheckIds []string    `json:"check_ids,omitempty"` // Optional. Check IDs.
+		Status   CheckStatus `json:"status,omitempty"`    // Optional. Status of check to be returned. Defaults to all statuses.
+		Offset   int         `json:"offset,omitempty"`    // Optional. Offset needed to return a specific subset of check. Default is 0.
+		Count    int         `json:"count,omitempty"`     // Optional. Number of check to be returned. Values between 1-1000 are accepted. Defaults to 100.
+	}
+	// GetStatsOptions for `getStats` api method.
+	GetStatsOptions struct {
+		StartAt time.Time `json:"start_at,omitempty"` // Optional. Date from which start calculating statistics. Defaults is current date minus 24 hours.
+		EndAt   time.Time `json:"end_at,omitempty"`   // Optional. The date on which to finish calculating statistics. Defaults is current date.
 	}
 )
 
+// maxTransportErrorBody is maximum size of body saved in TransportError.
+const maxTransportErrorBody = 1024
+
+// TransportError is returned if API server responded with something other than API's JSON.
+// For example, HTML error page of proxy or empty body.
+// In contrast, API's responses with "ok": false are returned as ApiError by Client methods.
+type TransportError struct {
+	StatusCode int         // HTTP status code of response.
+	Header     http.Header // Headers of response.
+	Body       []byte      // Body of response, truncated to 1024 bytes.
+}
+
+func newTransportError(resp *http.Response, body []byte) *TransportError {
+	if len(body) > maxTransportErrorBody {
+		body = body[:maxTransportErrorBody]
+	}
+	return &TransportError{StatusCode: resp.StatusCode, Header: resp.Header, Body: body}
+}
+
+// GetTransportError retrieves the TransportError from given error. If unsuccessfully returns nil.
+func GetTransportError(err error) *TransportError {
+	var transportErr *TransportError
+	if errors.As(err, &transportErr) {
+		return transportErr
+	}
+	return nil
+}
+
+func (e TransportError) Error() string {
+	if len(bytes.TrimSpace(e.Body)) == 0 {
+		return fmt.Sprintf("crypto-pay/api: empty response with status %d", e.StatusCode)
+	}
+	body := e.Body
+	if len(body) > 128 {
+		body = body[:128]
+	}
+	return fmt.Sprintf("crypto-pay/api: unexpected response with status %d: %q", e.StatusCode, body)
+}
+
+// ErrorCurrencyMismatch is matched by ValidationError if CreateInvoiceOptions mixes fields of crypto and fiat invoices.
+// For example, Asset is set for fiat invoice or AcceptedAssets is set for crypto invoice.
+var ErrorCurrencyMismatch = errors.New("crypto-pay/api: crypto and fiat fields of invoice are mixed")
+
+// ErrorCheckPinConflict is matched by ValidationError if CreateCheckOptions pins check both to user id and username.
+// Check can be pinned only to one user.
+var ErrorCheckPinConflict = errors.New("crypto-pay/api: check can be pinned either to user id or to username")
+
 type (
 	// BaseApiResponse  is contained in all api responses .
 	BaseApiResponse struct {
@@ -79,6 +173,11 @@ type (
 		BaseApiResponse
 		Result *Invoice `json:"result,omitempty"`
 	}
+	// DeleteInvoiceResponse for `deleteInvoice` method
+	DeleteInvoiceResponse struct {
+		BaseApiResponse
+		Result bool `json:"result,omitempty"`
+	}
 	// DoTransferResponse for `transfer` method
 	DoTransferResponse struct {
 		BaseApiResponse
@@ -91,6 +190,13 @@ type (
 			Items []Invoice `json:"items"`
 		} `json:"result,omitempty"`
 	}
+	// GetTransfersResponse for `getTransfers` method
+	GetTransfersResponse struct {
+		BaseApiResponse
+		Result struct {
+			Items []Transfer `json:"items"`
+		} `json:"result,omitempty"`
+	}
 	// GetBalanceResponse for `getBalance` method
 	GetBalanceResponse struct {
 		BaseApiResponse
@@ -106,17 +212,92 @@ type (
 		BaseApiResponse
 		Result []CurrencyInfo `json:"result,omitempty"`
 	}
+	// CreateCheckResponse for `createCheck` method
+	CreateCheckResponse struct {
+		BaseApiResponse
+		Result *Check `json:"result,omitempty"`
+	}
+	// DeleteCheckResponse for `deleteCheck` method
+	DeleteCheckResponse struct {
+		BaseApiResponse
+		Result bool `json:"result,omitempty"`
+	}
+	// GetChecksResponse for `getChecks` method
+	GetChecksResponse struct {
+		BaseApiResponse
+		Result struct {
+			Items []Check `json:"items"`
+		} `json:"result,omitempty"`
+	}
+	// GetStatsResponse for `getStats` method
+	GetStatsResponse struct {
+		BaseApiResponse
+		Result *AppStats `json:"result,omitempty"`
+	}
+)
+
+// RequestFormat is way of passing params of method to API.
+type RequestFormat int
+
+const (
+	// FormatJSON passes params in JSON body of POST request. It's default format.
+	// Params don't get into URL, so they don't appear in access logs and aren't limited by URL length.
+	FormatJSON RequestFormat = iota
+	// FormatQuery passes params in query string of GET request.
+	FormatQuery
 )
 
+// apiParams is params of API method. It must be encodable to query string and to JSON.
+type apiParams interface {
+	QueryParams() string
+}
+
+// idParam is params of methods which take only ID of object (deleteInvoice, deleteCheck).
+type idParam struct {
+	name string
+	id   int
+}
+
+// QueryParams encode ID to query params.
+func (p idParam) QueryParams() string {
+	return createEncodeQuery(map[string]string{p.name: strconv.Itoa(p.id)})
+}
+
+// MarshalJSON encode ID to JSON body.
+func (p idParam) MarshalJSON() ([]byte, error) {
+	return json.Marshal(map[string]int{p.name: p.id})
+}
+
 type ApiCore struct {
 	token      string
 	url        string
 	httpClient *http.Client
+	// retry is policy of retries for transient failures.
+	retry RetryPolicy
+	// limiter is client-side rate limiter, shared between copies of ApiCore.
+	limiter *rateLimiter
+	// format is way of passing params to API.
+	format RequestFormat
 }
 
 // NewApi returns new ApiCore
 func NewApi(token, url string, httpClient *http.Client) *ApiCore {
-	return &ApiCore{token: token, url: url, httpClient: httpClient}
+	return &ApiCore{token: token, url: url, httpClient: httpClient, limiter: newRateLimiter(RateLimitSettings{})}
+}
+
+// SetRequestFormat sets way of passing params to API. Default FormatJSON.
+func (c *ApiCore) SetRequestFormat(format RequestFormat) {
+	c.format = format
+}
+
+// SetRateLimit sets budgets of client-side rate limiter. By default, only Retry-After of 429 responses is honored.
+func (c *ApiCore) SetRateLimit(settings RateLimitSettings) {
+	c.limiter = newRateLimiter(settings)
+}
+
+// SetRetryPolicy sets policy of retries for transient failures. By default, requests aren't retried.
+func (c *ApiCore) SetRetryPolicy(policy RetryPolicy) {
+	c.retry = policy
 }
 
 // urlFmt formatting URL, paste query params
@@ -128,25 +309,112 @@ func (c ApiCore) urlFmt(method string, queryParams string) string {
 	return methodUrl + "?" + queryParams
 }
 
-// apiCall make request to API and deserialization response body in dest argument
-func (c ApiCore) apiCall(method, queryParams string, dest interface{}) error {
-	req, err := http.NewRequest("GET", c.urlFmt(method, queryParams), nil)
+// newRequest creates request to API method with params encoded according to request format.
+func (c ApiCore) newRequest(ctx context.Context, method string, params apiParams) (*http.Request, error) {
+	var req *http.Request
+	var err error
+	switch c.format {
+	case FormatQuery:
+		queryParams := emptyQuery
+		if params != nil {
+			queryParams = params.QueryParams()
+		}
+		req, err = http.NewRequestWithContext(ctx, "GET", c.urlFmt(method, queryParams), nil)
+	default:
+		var body io.Reader = http.NoBody
+		if params != nil {
+			data, err := json.Marshal(params)
+			if err != nil {
+				return nil, err
+			}
+			body = bytes.NewReader(data)
+		}
+		req, err = http.NewRequestWithContext(ctx, "POST", c.urlFmt(method, emptyQuery), body)
+		if err == nil && params != nil {
+			req.Header.Set("Content-Type", "application/json")
+		}
+	}
 	if err != nil {
-		return err
+		return nil, err
 	}
 	req.Header.Set(headerTokenName, c.token)
+	return req, nil
+}
+
+// apiCall make request to API and deserialization response body in dest argument.
+// Cancellation of ctx aborts both the round trip and reading of the response body.
+//
+// Transient failures are retried according to retry policy.
+func (c ApiCore) apiCall(ctx context.Context, method string, params apiParams, dest interface{}) error {
+	attempts := c.retry.attempts(method, params)
+	for attempt := 1; ; attempt++ {
+		retry, err := c.doCall(ctx, method, params, dest, attempt < attempts)
+		if !retry || attempt >= attempts {
+			return err
+		}
+		if err := sleep(ctx, c.retry.delay(attempt)); err != nil {
+			return err
+		}
+	}
+}
+
+// doCall make single attempt of request to API. Returns whether attempt can be retried.
+// If canRetry is false, response with retryable status is decoded as usual.
+//
+// Response body is always drained and closed, so connection can be reused.
+// If response isn't API's JSON returns TransportError.
+func (c ApiCore) doCall(ctx context.Context, method string, params apiParams, dest interface{}, canRetry bool) (bool, error) {
+	req, err := c.newRequest(ctx, method, params)
+	if err != nil {
+		return false, err
+	}
+	if err := c.limiter.wait(ctx, method); err != nil {
+		return false, err
+	}
 	resp, err := c.httpClient.Do(req)
 	if err != nil {
-		return err
+		return c.retry.retryableError(err), err
+	}
+	defer func() {
+		io.Copy(io.Discard, resp.Body)
+		resp.Body.Close()
+	}()
+	if resp.StatusCode == http.StatusTooManyRequests {
+		c.limiter.pause(parseRetryAfter(resp.Header.Get("Retry-After")))
+	}
+	body, err := io.ReadAll(resp.Body)
+	if err != nil {
+		return c.retry.retryableError(err), err
+	}
+	if canRetry && c.retry.retryableStatus(resp.StatusCode) {
+		return true, newTransportError(resp, body)
+	}
+	if !isApiResponse(body) {
+		return false, newTransportError(resp, body)
 	}
-	return json.NewDecoder(resp.Body).Decode(&dest)
+	return false, json.NewDecoder(bytes.NewReader(body)).Decode(dest)
+}
 
+// isApiResponse reports whether body is JSON object of API response with "ok" field.
+func isApiResponse(body []byte) bool {
+	var probe struct {
+		Ok *bool `json:"ok"`
+	}
+	if err := json.NewDecoder(bytes.NewReader(body)).Decode(&probe); err != nil {
+		return false
+	}
+	return probe.Ok != nil
 }
 
 // GetMe call api/getMe.
 func (c ApiCore) GetMe() (*GetMeResponse, error) {
+	return c.GetMeCtx(context.Background())
+}
+
+// GetMeCtx call api/getMe with given context.
+func (c ApiCore) GetMeCtx(ctx context.Context) (*GetMeResponse, error) {
 	appInfo := new(GetMeResponse)
-	if err := c.apiCall(getMeMethod, emptyQuery, appInfo); err != nil {
+	if err := c.apiCall(ctx, MethodGetMe, nil, appInfo); err != nil {
 		return nil, err
 	}
 	return appInfo, nil
@@ -154,17 +422,41 @@ func (c ApiCore) GetMe() (*GetMeResponse, error) {
 
 // CreateInvoice call api/createInvoice.
 func (c ApiCore) CreateInvoice(opt CreateInvoiceOptions) (*CreateInvoiceResponse, error) {
+	return c.CreateInvoiceCtx(context.Background(), opt)
+}
+
+// CreateInvoiceCtx call api/createInvoice with given context.
+func (c ApiCore) CreateInvoiceCtx(ctx context.Context, opt CreateInvoiceOptions) (*CreateInvoiceResponse, error) {
 	newInvoice := new(CreateInvoiceResponse)
-	if err := c.apiCall(createInvoiceMethod, opt.QueryParams(), newInvoice); err != nil {
+	if err := c.apiCall(ctx, MethodCreateInvoice, opt, newInvoice); err != nil {
 		return nil, err
 	}
 	return newInvoice, nil
 }
 
+// DeleteInvoice call api/deleteInvoice.
+func (c ApiCore) DeleteInvoice(invoiceId int) (*DeleteInvoiceResponse, error) {
+	return c.DeleteInvoiceCtx(context.Background(), invoiceId)
+}
+
+// DeleteInvoiceCtx call api/deleteInvoice with given context.
+func (c ApiCore) DeleteInvoiceCtx(ctx context.Context, invoiceId int) (*DeleteInvoiceResponse, error) {
+	deleted := new(DeleteInvoiceResponse)
+	if err := c.apiCall(ctx, MethodDeleteInvoice, idParam{"invoice_id", invoiceId}, deleted); err != nil {
+		return nil, err
+	}
+	return deleted, nil
+}
+
 // DoTransfer call api/transfer.
 func (c ApiCore) DoTransfer(opt DoTransferOptions) (*DoTransferResponse, error) {
+	return c.DoTransferCtx(context.Background(), opt)
+}
+
+// DoTransferCtx call api/transfer with given context.
+func (c ApiCore) DoTransferCtx(ctx context.Context, opt DoTransferOptions) (*DoTransferResponse, error) {
 	newTransfer := new(DoTransferResponse)
-	if err := c.apiCall(transferMethod, opt.QueryParams(), newTransfer); err != nil {
+	if err := c.apiCall(ctx, MethodTransfer, opt, newTransfer); err != nil {
 		return nil, err
 	}
 	return newTransfer, nil
@@ -172,21 +464,49 @@ func (c ApiCore) DoTransfer(opt DoTransferOptions) (*DoTransferResponse, error)
 
 // GetInvoices call api/getInvoices. Set opt as nil for empty API params.
 func (c ApiCore) GetInvoices(opt *GetInvoicesOptions) (*GetInvoicesResponse, error) {
+	return c.GetInvoicesCtx(context.Background(), opt)
+}
+
+// GetInvoicesCtx call api/getInvoices with given context. Set opt as nil for empty API params.
+func (c ApiCore) GetInvoicesCtx(ctx context.Context, opt *GetInvoicesOptions) (*GetInvoicesResponse, error) {
 	invoices := new(GetInvoicesResponse)
-	var queryParams string
+	var params apiParams
 	if opt != nil {
-		queryParams = opt.QueryParams()
+		params = opt
 	}
-	if err := c.apiCall(getInvoicesMethod, queryParams, invoices); err != nil {
+	if err := c.apiCall(ctx, MethodGetInvoices, params, invoices); err != nil {
 		return nil, err
 	}
 	return invoices, nil
 }
 
+// GetTransfers call api/getTransfers. Set opt as nil for empty API params.
+func (c ApiCore) GetTransfers(opt *GetTransfersOptions) (*GetTransfersResponse, error) {
+	return c.GetTransfersCtx(context.Background(), opt)
+}
+
+// GetTransfersCtx call api/getTransfers with given context. Set opt as nil for empty API params.
+func (c ApiCore) GetTransfersCtx(ctx context.Context, opt *GetTransfersOptions) (*GetTransfersResponse, error) {
+	transfers := new(GetTransfersResponse)
+	var params apiParams
+	if opt != nil {
+		params = opt
+	}
+	if err := c.apiCall(ctx, MethodGetTransfers, params, transfers); err != nil {
+		return nil, err
+	}
+	return transfers, nil
+}
+
 // GetBalance call api/getBalance.
 func (c ApiCore) GetBalance() (*GetBalanceResponse, error) {
+	return c.GetBalanceCtx(context.Background())
+}
+
+// GetBalanceCtx call api/getBalance with given context.
+func (c ApiCore) GetBalanceCtx(ctx context.Context) (*GetBalanceResponse, error) {
 	balanceInfo := new(GetBalanceResponse)
-	if err := c.apiCall(getBalanceMethod, emptyQuery, balanceInfo); err != nil {
+	if err := c.apiCall(ctx, MethodGetBalance, nil, balanceInfo); err != nil {
 		return nil, err
 	}
 	return balanceInfo, nil
@@ -194,8 +514,13 @@ func (c ApiCore) GetBalance() (*GetBalanceResponse, error) {
 
 // GetExchangeRates call api/getExchangeRates.
 func (c ApiCore) GetExchangeRates() (*GetExchangeRatesResponse, error) {
+	return c.GetExchangeRatesCtx(context.Background())
+}
+
+// GetExchangeRatesCtx call api/getExchangeRates with given context.
+func (c ApiCore) GetExchangeRatesCtx(ctx context.Context) (*GetExchangeRatesResponse, error) {
 	exchangesInfo := new(GetExchangeRatesResponse)
-	if err := c.apiCall(getExchangeRatesMethod, emptyQuery, exchangesInfo); err != nil {
+	if err := c.apiCall(ctx, MethodGetExchangeRates, nil, exchangesInfo); err != nil {
 		return nil, err
 	}
 	return exchangesInfo, nil
@@ -203,13 +528,82 @@ func (c ApiCore) GetExchangeRates() (*GetExchangeRatesResponse, error) {
 
 // GetCurrencies call api/getCurrencies.
 func (c ApiCore) GetCurrencies() (*GetCurrenciesResponse, error) {
+	return c.GetCurrenciesCtx(context.Background())
+}
+
+// GetCurrenciesCtx call api/getCurrencies with given context.
+func (c ApiCore) GetCurrenciesCtx(ctx context.Context) (*GetCurrenciesResponse, error) {
 	currencyInfo := new(GetCurrenciesResponse)
-	if err := c.apiCall(getCurrenciesMethod, emptyQuery, currencyInfo); err != nil {
+	if err := c.apiCall(ctx, MethodGetCurrencies, nil, currencyInfo); err != nil {
 		return nil, err
 	}
 	return currencyInfo, nil
 }
 
+// CreateCheck call api/createCheck.
+func (c ApiCore) CreateCheck(opt CreateCheckOptions) (*CreateCheckResponse, error) {
+	return c.CreateCheckCtx(context.Background(), opt)
+}
+
+// CreateCheckCtx call api/createCheck with given context.
+func (c ApiCore) CreateCheckCtx(ctx context.Context, opt CreateCheckOptions) (*CreateCheckResponse, error) {
+	newCheck := new(CreateCheckResponse)
+	if err := c.apiCall(ctx, MethodCreateCheck, opt, newCheck); err != nil {
+		return nil, err
+	}
+	return newCheck, nil
+}
+
+// DeleteCheck call api/deleteCheck.
+func (c ApiCore) DeleteCheck(checkId int) (*DeleteCheckResponse, error) {
+	return c.DeleteCheckCtx(context.Background(), checkId)
+}
+
+// DeleteCheckCtx call api/deleteCheck with given context.
+func (c ApiCore) DeleteCheckCtx(ctx context.Context, checkId int) (*DeleteCheckResponse, error) {
+	deleted := new(DeleteCheckResponse)
+	if err := c.apiCall(ctx, MethodDeleteCheck, idParam{"check_id", checkId}, deleted); err != nil {
+		return nil, err
+	}
+	return deleted, nil
+}
+
+// GetChecks call api/getChecks. Set opt as nil for empty API params.
+func (c ApiCore) GetChecks(opt *GetChecksOptions) (*GetChecksResponse, error) {
+	return c.GetChecksCtx(context.Background(), opt)
+}
+
+// GetChecksCtx call api/getChecks with given context. Set opt as nil for empty API params.
+func (c ApiCore) GetChecksCtx(ctx context.Context, opt *GetChecksOptions) (*GetChecksResponse, error) {
+	checks := new(GetChecksResponse)
+	var params apiParams
+	if opt != nil {
+		params = opt
+	}
+	if err := c.apiCall(ctx, MethodGetChecks, params, checks); err != nil {
+		return nil, err
+	}
+	return checks, nil
+}
+
+// GetStats call api/getStats. Set opt as nil for empty API params.
+func (c ApiCore) GetStats(opt *GetStatsOptions) (*GetStatsResponse, error) {
+	return c.GetStatsCtx(context.Background(), opt)
+}
+
+// GetStatsCtx call api/getStats with given context. Set opt as nil for empty API params.
+func (c ApiCore) GetStatsCtx(ctx context.Context, opt *GetStatsOptions) (*GetStatsResponse, error) {
+	stats := new(GetStatsResponse)
+	var params apiParams
+	if opt != nil {
+		params = opt
+	}
+	if err := c.apiCall(ctx, MethodGetStats, params, stats); err != nil {
+		return nil, err
+	}
+	return stats, nil
+}
+
 // createEncodeQuery create url.Values from given map and encode to string.
 func createEncodeQuery(params map[string]string) string {
 	values := url.Values{}
@@ -225,14 +619,18 @@ func createEncodeQuery(params map[string]string) string {
 func (opt CreateInvoiceOptions) QueryParams() string {
 	params := map[string]string{
 		"asset":           opt.Asset.String(),
-		"amount":          opt.Amount,
+		"amount":          amountParam(opt.Amount),
 		"description":     opt.Description,
 		"hidden_message":  opt.HiddenMessage,
 		"paid_btn_url":    opt.PaidButtonUrl,
 		"payload":         opt.Payload,
 		"paid_btn_name":   opt.PaidButtonName.String(),
-		"allow_comments":  strconv.FormatBool(opt.AllowComments),
-		"allow_anonymous": strconv.FormatBool(opt.AllowAnonymous),
+		"allow_comments":  boolParam(opt.AllowComments),
+		"allow_anonymous": boolParam(opt.AllowAnonymous),
+		"currency_type":   opt.CurrencyType.String(),
+		"fiat":            opt.Fiat.String(),
+		"accepted_assets": joinAssets(opt.AcceptedAssets),
+		"swap_to":         opt.SwapTo.String(),
 	}
 	if opt.ExpiresIn != 0 {
 		params["expires_in"] = strconv.Itoa(opt.ExpiresIn)
@@ -241,30 +639,186 @@ func (opt CreateInvoiceOptions) QueryParams() string {
 
 }
 
+// Bool returns pointer to v. Use it for optional boolean fields of options,
+// nil value of these fields means default value of API.
+func Bool(v bool) *bool {
+	return &v
+}
+
+// boolParam encodes optional boolean to query param. Returns empty string if value is unset.
+func boolParam(v *bool) string {
+	if v == nil {
+		return emptyQuery
+	}
+	return strconv.FormatBool(*v)
+}
+
+// joinAssets joins currency codes with comma.
+func joinAssets(assets []Asset) string {
+	codes := make([]string, len(assets))
+	for i, asset := range assets {
+		codes[i] = asset.String()
+	}
+	return strings.Join(codes, ",")
+}
+
 // QueryParams encode options to query params for `transfer` method.
 func (opt DoTransferOptions) QueryParams() string {
 	return createEncodeQuery(map[string]string{
 		"user_id":                   strconv.Itoa(opt.UserId),
 		"asset":                     opt.Asset.String(),
-		"amount":                    opt.Amount,
+		"amount":                    amountParam(opt.Amount),
 		"spend_id":                  opt.SpendId,
 		"comment":                   opt.Comment,
-		"disable_send_notification": strconv.FormatBool(opt.DisableSendNotification),
+		"disable_send_notification": boolParam(opt.DisableSendNotification),
 	})
 
 }
 
 // QueryParams encode options to query params for `getInvoices` method.
 func (opt GetInvoicesOptions) QueryParams() string {
+	params := map[string]string{
+		"asset":       opt.Asset.String(),
+		"status":      opt.Status.String(),
+		"offset":      strconv.Itoa(opt.Offset),
+		"invoice_ids": strings.Join(opt.InvoiceIds, ","),
+	}
+	if count := pageCount(opt.Count); count != 0 {
+		params["count"] = strconv.Itoa(count)
+	}
+	return createEncodeQuery(params)
+}
+
+// QueryParams encode options to query params for `getTransfers` method.
+func (opt GetTransfersOptions) QueryParams() string {
 	params := map[string]string{
 		"asset":        opt.Asset.String(),
-		"status":       opt.Status.String(),
+		"spend_id":     opt.SpendId,
 		"offset":       strconv.Itoa(opt.Offset),
-		"invoices_ids": strings.Join(opt.InvoiceIds, ","),
+		"transfer_ids": strings.Join(opt.TransferIds, ","),
+	}
+	if count := pageCount(opt.Count); count != 0 {
+		params["count"] = strconv.Itoa(count)
+	}
+	return createEncodeQuery(params)
+}
+
+// QueryParams encode options to query params for `createCheck` method.
+func (opt CreateCheckOptions) QueryParams() string {
+	params := map[string]string{
+		"asset":           opt.Asset.String(),
+		"amount":          amountParam(opt.Amount),
+		"pin_to_username": opt.PinToUsername,
+	}
+	if opt.PinToUserId != 0 {
+		params["pin_to_user_id"] = strconv.Itoa(opt.PinToUserId)
+	}
+	return createEncodeQuery(params)
+}
+
+// QueryParams encode options to query params for `getChecks` method.
+func (opt GetChecksOptions) QueryParams() string {
+	params := map[string]string{
+		"asset":     opt.Asset.String(),
+		"status":    opt.Status.String(),
+		"offset":    strconv.Itoa(opt.Offset),
+		"check_ids": strings.Join(opt.CheckIds, ","),
+	}
+	if count := pageCount(opt.Count); count != 0 {
+		params["count"] = strconv.Itoa(count)
 	}
-	// Values between 1-1000 are accepted. Defaults to 100.
-	if (0 < opt.Count && opt.Count < 1000) && opt.Count != 100 {
-		params["count"] = strconv.Itoa(opt.Count)
+	return createEncodeQuery(params)
+}
+
+// QueryParams encode options to query params for `getStats` method.
+// Dates are encoded in ISO 8601 format, zero dates are omitted.
+func (opt GetStatsOptions) QueryParams() string {
+	params := make(map[string]string)
+	if !opt.StartAt.IsZero() {
+		params["start_at"] = opt.StartAt.UTC().Format(time.RFC3339)
+	}
+	if !opt.EndAt.IsZero() {
+		params["end_at"] = opt.EndAt.UTC().Format(time.RFC3339)
 	}
 	return createEncodeQuery(params)
 }
+
+// pageCount returns count for pagination params or 0 if count is default or invalid.
+// Values between 1-1000 are accepted. Defaults to 100.
+func pageCount(count int) int {
+	if (0 < count && count <= maxPageCount) && count != 100 {
+		return count
+	}
+	return 0
+}
+
+// MarshalJSON encode options to JSON body for `createInvoice` method.
+func (opt CreateInvoiceOptions) MarshalJSON() ([]byte, error) {
+	type options CreateInvoiceOptions
+	return json.Marshal(struct {
+		options
+		Amount         string `json:"amount,omitempty"`
+		AcceptedAssets string `json:"accepted_assets,omitempty"`
+	}{options(opt), amountParam(opt.Amount), joinAssets(opt.AcceptedAssets)})
+}
+
+// MarshalJSON encode options to JSON body for `transfer` method.
+func (opt DoTransferOptions) MarshalJSON() ([]byte, error) {
+	type options DoTransferOptions
+	return json.Marshal(struct {
+		options
+		Amount string `json:"amount,omitempty"`
+	}{options(opt), amountParam(opt.Amount)})
+}
+
+// MarshalJSON encode options to JSON body for `getInvoices` method.
+func (opt GetInvoicesOptions) MarshalJSON() ([]byte, error) {
+	type options GetInvoicesOptions
+	return json.Marshal(struct {
+		options
+		InvoiceIds string `json:"invoice_ids,omitempty"`
+		Count      int    `json:"count,omitempty"`
+	}{options(opt), strings.Join(opt.InvoiceIds, ","), pageCount(opt.Count)})
+}
+
+// MarshalJSON encode options to JSON body for `getTransfers` method.
+func (opt GetTransfersOptions) MarshalJSON() ([]byte, error) {
+	type options GetTransfersOptions
+	return json.Marshal(struct {
+		options
+		TransferIds string `json:"transfer_ids,omitempty"`
+		Count       int    `json:"count,omitempty"`
+	}{options(opt), strings.Join(opt.TransferIds, ","), pageCount(opt.Count)})
+}
+
+// MarshalJSON encode options to JSON body for `createCheck` method.
+func (opt CreateCheckOptions) MarshalJSON() ([]byte, error) {
+	type options CreateCheckOptions
+	return json.Marshal(struct {
+		options
+		Amount string `json:"amount,omitempty"`
+	}{options(opt), amountParam(opt.Amount)})
+}
+
+// MarshalJSON encode options to JSON body for `getChecks` method.
+func (opt GetChecksOptions) MarshalJSON() ([]byte, error) {
+	type options GetChecksOptions
+	return json.Marshal(struct {
+		options
+		CheckIds string `json:"check_ids,omitempty"`
+		Count    int    `json:"count,omitempty"`
+	}{options(opt), strings.Join(opt.CheckIds, ","), pageCount(opt.Count)})
+}
+
+// MarshalJSON encode options to JSON body for `getStats` method.
+// Dates are encoded in ISO 8601 format, zero dates are omitted.
+func (opt GetStatsOptions) MarshalJSON() ([]byte, error) {
+	params := make(map[string]string)
+	if !opt.StartAt.IsZero() {
+		params["start_at"] = opt.StartAt.UTC().Format(time.RFC3339)
+	}
+	if !opt.EndAt.IsZero() {
+		params["end_at"] = opt.EndAt.UTC().Format(time.RFC3339)
+	}
+	return json.Marshal(params)
+}
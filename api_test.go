@@ -1,14 +1,20 @@
 package cryptopay
 
 import (
+	"bytes"
+	"context"
 	"encoding/json"
+	"errors"
 	"fmt"
+	"io"
 	"math/rand"
 	"net/http"
 	"net/http/httptest"
+	"net/url"
 	"strconv"
 	"strings"
 	"sync"
+	"sync/atomic"
 	"testing"
 	"time"
 )
@@ -52,11 +58,205 @@ func TestApiCore_urlFmt(t *testing.T) {
 }
 
 func TestApiCore_apiCall(t *testing.T) {
-	if getApi().apiCall("%^&escape test", emptyQuery, nil) == nil {
+	if getApi().apiCall(context.Background(), "%^&escape test", nil, nil) == nil {
 		t.Error("http.NewRequest pass invalid URL escape")
 	}
 }
 
+// closeTracker counts closed response bodies.
+type closeTracker struct {
+	rt     http.RoundTripper
+	closed int32
+}
+
+type trackedBody struct {
+	io.ReadCloser
+	closed *int32
+}
+
+func (b trackedBody) Close() error {
+	atomic.AddInt32(b.closed, 1)
+	return b.ReadCloser.Close()
+}
+
+func (c *closeTracker) RoundTrip(r *http.Request) (*http.Response, error) {
+	resp, err := c.rt.RoundTrip(r)
+	if err == nil {
+		resp.Body = trackedBody{resp.Body, &c.closed}
+	}
+	return resp, err
+}
+
+func TestApiCore_TransportError(t *testing.T) {
+	var cases = []struct {
+		name       string
+		code       int
+		body       string
+		transport  bool
+		apiFailure bool
+	}{
+		{name: "html", code: http.StatusBadGateway, body: "<html><body>502 Bad Gateway</body></html>", transport: true},
+		{name: "empty", code: http.StatusOK, body: "", transport: true},
+		{name: "json without ok", code: http.StatusOK, body: `{"message":"hello"}`, transport: true},
+		{name: "api error", code: http.StatusBadRequest, body: `{"ok":false,"error":{"code":400,"name":"AMOUNT_TOO_SMALL"}}`, apiFailure: true},
+		{name: "success", code: http.StatusOK, body: `{"ok":true,"result":{"app_id":1}}`},
+	}
+	for _, tc := range cases {
+		t.Run(tc.name, func(t *testing.T) {
+			s := httptest.NewServer(http.HandlerFunc(func(rw http.ResponseWriter, r *http.Request) {
+				rw.Header().Set("X-Test", tc.name)
+				rw.WriteHeader(tc.code)
+				rw.Write([]byte(tc.body))
+			}))
+			defer s.Close()
+			tracker := &closeTracker{rt: s.Client().Transport}
+			api := NewApi("1:test_token", s.URL, &http.Client{Transport: tracker})
+			me, err := api.GetMe()
+			if atomic.LoadInt32(&tracker.closed) != 1 {
+				t.Error("response body not closed")
+			}
+			transportErr := GetTransportError(err)
+			if tc.transport {
+				if transportErr == nil {
+					t.Fatalf("err(%v) is not TransportError", err)
+				}
+				if transportErr.StatusCode != tc.code || string(transportErr.Body) != tc.body || transportErr.Header.Get("X-Test") != tc.name {
+					t.Errorf("unexpected error %#v", transportErr)
+				}
+				return
+			}
+			if err != nil {
+				t.Fatal(err)
+			}
+			if me.IsSuccessfully() == tc.apiFailure {
+				t.Errorf("IsSuccessfully() == %v", me.IsSuccessfully())
+			}
+		})
+	}
+}
+
+func TestTransportError_Error(t *testing.T) {
+	err := newTransportError(&http.Response{StatusCode: 502}, bytes.Repeat([]byte("a"), 2000))
+	if len(err.Body) != maxTransportErrorBody {
+		t.Errorf("body not truncated, len(%d)", len(err.Body))
+	}
+	if !strings.HasPrefix(err.Error(), "crypto-pay/api: unexpected response with status 502") {
+		t.Errorf("unexpected message %q", err.Error())
+	}
+	if msg := (TransportError{StatusCode: 200}).Error(); msg != "crypto-pay/api: empty response with status 200" {
+		t.Errorf("unexpected message %q", msg)
+	}
+}
+
+func TestApiCore_RequestFormat(t *testing.T) {
+	var got *http.Request
+	var body []byte
+	s := httptest.NewServer(http.HandlerFunc(func(rw http.ResponseWriter, r *http.Request) {
+		got = r
+		body, _ = io.ReadAll(r.Body)
+		rw.Write([]byte(`{"ok":true,"result":{"items":[]}}`))
+	}))
+	defer s.Close()
+	api := NewApi("1:test_token", s.URL, s.Client())
+	opt := &GetInvoicesOptions{Asset: TON, InvoiceIds: []string{"1", "2"}, Count: 5}
+
+	t.Run("json", func(t *testing.T) {
+		if _, err := api.GetInvoices(opt); err != nil {
+			t.Fatal(err)
+		}
+		if got.Method != "POST" || got.URL.RawQuery != "" || got.Header.Get("Content-Type") != "application/json" {
+			t.Errorf("unexpected request %s %s", got.Method, got.URL)
+		}
+		if string(body) != `{"asset":"TON","invoice_ids":"1,2","count":5}` {
+			t.Errorf("unexpected body %s", body)
+		}
+	})
+	t.Run("query", func(t *testing.T) {
+		api.SetRequestFormat(FormatQuery)
+		if _, err := api.GetInvoices(opt); err != nil {
+			t.Fatal(err)
+		}
+		if got.Method != "GET" || got.URL.RawQuery != opt.QueryParams() || len(body) != 0 {
+			t.Errorf("unexpected request %s %s", got.Method, got.URL)
+		}
+		if ids := got.URL.Query().Get("invoice_ids"); ids != "1,2" {
+			t.Errorf("invoice_ids(%q) != 1,2", ids)
+		}
+	})
+}
+
+func TestApiCore_FormatQuery(t *testing.T) {
+	api := getApi()
+	api.SetRequestFormat(FormatQuery)
+	r, err := api.GetInvoices(&GetInvoicesOptions{Asset: BTC, Status: StatusActive})
+	if err != nil {
+		t.Fatal(err)
+	}
+	if len(r.Result.Items) != 2 {
+		t.Errorf("count(%d) != 2", len(r.Result.Items))
+	}
+}
+
+func TestOptions_MarshalJSON(t *testing.T) {
+	var cases = []struct {
+		name     string
+		opt      interface{}
+		expected string
+	}{
+		{
+			name: "createInvoice",
+			opt: CreateInvoiceOptions{
+				CurrencyType:   CurrencyFiat,
+				Fiat:           USD,
+				AcceptedAssets: []Asset{USDT, TON},
+				Amount:         MustParseAmount("9.99"),
+				Payload:        strings.Repeat("p", 3),
+				AllowAnonymous: Bool(false),
+			},
+			expected: `{"currency_type":"fiat","fiat":"USD","payload":"ppp","allow_anonymous":false,"amount":"9.99","accepted_assets":"USDT,TON"}`,
+		},
+		{
+			name:     "transfer",
+			opt:      DoTransferOptions{UserId: 1, Asset: TON, Amount: NewAmount(5, 1), SpendId: "s"},
+			expected: `{"user_id":1,"asset":"TON","spend_id":"s","amount":"0.5"}`,
+		},
+		{
+			name:     "getChecks default count",
+			opt:      GetChecksOptions{Status: CheckStatusActive, Count: 100},
+			expected: `{"status":"active"}`,
+		},
+		{
+			name:     "getStats",
+			opt:      GetStatsOptions{EndAt: time.Date(2022, 5, 1, 0, 0, 0, 0, time.UTC)},
+			expected: `{"end_at":"2022-05-01T00:00:00Z"}`,
+		},
+		{
+			name:     "id",
+			opt:      idParam{"check_id", 7},
+			expected: `{"check_id":7}`,
+		},
+	}
+	for _, tc := range cases {
+		t.Run(tc.name, func(t *testing.T) {
+			data, err := json.Marshal(tc.opt)
+			if err != nil {
+				t.Fatal(err)
+			}
+			if string(data) != tc.expected {
+				t.Errorf("expected %s, but got %s", tc.expected, data)
+			}
+		})
+	}
+}
+
+func TestApiCore_Context(t *testing.T) {
+	ctx, cancel := context.WithCancel(context.Background())
+	cancel()
+	if _, err := getApi().GetMeCtx(ctx); !errors.Is(err, context.Canceled) {
+		t.Errorf("err(%v) != context.Canceled", err)
+	}
+}
+
 func TestApiCore_GetMe(t *testing.T) {
 	me, err := getApi().GetMe()
 	if err != nil {
@@ -70,17 +270,95 @@ func TestApiCore_GetMe(t *testing.T) {
 func TestApiCore_CreateInvoice(t *testing.T) {
 	inv, err := getApi().CreateInvoice(CreateInvoiceOptions{
 		Asset:     TON,
-		Amount:    "3.14",
+		Amount:    MustParseAmount("3.14"),
 		ExpiresIn: 1,
 	})
 	if err != nil {
 		t.Error(err)
 	}
-	if inv.Result.Amount != "3.14" {
+	if inv.Result.Amount.String() != "3.14" {
 		t.Errorf("amount(%s) != 3.14", inv.Result.Amount)
 	}
 }
 
+func TestApiCore_CreateInvoice_Fiat(t *testing.T) {
+	inv, err := getApi().CreateInvoice(CreateInvoiceOptions{
+		CurrencyType:   CurrencyFiat,
+		Fiat:           EUR,
+		AcceptedAssets: []Asset{USDT, TON},
+		Amount:         MustParseAmount("10"),
+	})
+	if err != nil {
+		t.Fatal(err)
+	}
+	if inv.Result.CurrencyType != CurrencyFiat || inv.Result.Fiat != EUR {
+		t.Errorf("currency_type(%s) != fiat || fiat(%s) != EUR", inv.Result.CurrencyType, inv.Result.Fiat)
+	}
+	if len(inv.Result.AcceptedAssets) != 2 || inv.Result.AcceptedAssets[1] != TON {
+		t.Errorf("accepted_assets(%v) != [USDT TON]", inv.Result.AcceptedAssets)
+	}
+}
+
+func TestApiCore_CreateInvoice_Swap(t *testing.T) {
+	api := getApi()
+	t.Run("swap", func(t *testing.T) {
+		inv, err := api.CreateInvoice(CreateInvoiceOptions{
+			Asset:  TON,
+			Amount: MustParseAmount("1.5"),
+			SwapTo: USDT,
+		})
+		if err != nil {
+			t.Fatal(err)
+		}
+		if !inv.Result.IsSwapped || inv.Result.SwappedTo != USDT {
+			t.Errorf("is_swapped(%v) != true || swapped_to(%s) != USDT", inv.Result.IsSwapped, inv.Result.SwappedTo)
+		}
+		if inv.Result.SwappedRate.String() != "2" || inv.Result.SwappedOutput.String() != "3" || inv.Result.SwappedUsdAmount.String() != "3" {
+			t.Errorf("unexpected swap result %#v", inv.Result)
+		}
+	})
+	t.Run("without swap", func(t *testing.T) {
+		inv, err := api.CreateInvoice(CreateInvoiceOptions{
+			Asset:  TON,
+			Amount: MustParseAmount("1.5"),
+		})
+		if err != nil {
+			t.Fatal(err)
+		}
+		if inv.Result.IsSwapped || inv.Result.SwappedTo != "" {
+			t.Errorf("unexpected swap result %#v", inv.Result)
+		}
+	})
+}
+
+func TestCreateInvoiceOptions_QueryParams(t *testing.T) {
+	opt := CreateInvoiceOptions{Asset: TON, Amount: NewAmount(1, 0)}
+	if got := opt.QueryParams(); got != "amount=1&asset=TON" {
+		t.Errorf("unset booleans are sent: %q", got)
+	}
+	opt.AllowComments, opt.AllowAnonymous = Bool(false), Bool(true)
+	if got := opt.QueryParams(); got != "allow_anonymous=true&allow_comments=false&amount=1&asset=TON" {
+		t.Errorf("unexpected query %q", got)
+	}
+	if got := (DoTransferOptions{DisableSendNotification: Bool(true)}).QueryParams(); got != "disable_send_notification=true&user_id=0" {
+		t.Errorf("unexpected query %q", got)
+	}
+}
+
+func TestApiCore_DeleteInvoice(t *testing.T) {
+	api := getApi()
+	r, err := api.DeleteInvoice(1)
+	if err != nil {
+		t.Error(err)
+	}
+	if !r.IsSuccessfully() || !r.Result {
+		t.Error("invoice not deleted")
+	}
+	if r, _ = api.DeleteInvoice(0); r.IsSuccessfully() {
+		t.Error("deleted paid invoice")
+	}
+}
+
 func TestApiCore_DoTransfer(t *testing.T) {
 	api := getApi()
 	t.Run("with error", func(t *testing.T) {
@@ -93,7 +371,7 @@ func TestApiCore_DoTransfer(t *testing.T) {
 	t.Run("correct", func(t *testing.T) {
 		_, err := api.DoTransfer(DoTransferOptions{
 			Asset:   BTC,
-			Amount:  "4.4",
+			Amount:  MustParseAmount("4.4"),
 			SpendId: "random?",
 		})
 		if err != nil {
@@ -144,6 +422,46 @@ func TestApiCore_GetInvoices(t *testing.T) {
 	})
 }
 
+func TestApiCore_GetTransfers(t *testing.T) {
+	api := getApi()
+	t.Run("empty params", func(t *testing.T) {
+		transfers, err := api.GetTransfers(nil)
+		if err != nil {
+			t.Error(err)
+		}
+		if len(transfers.Result.Items) != 2 {
+			t.Errorf("count(%d) != 2", len(transfers.Result.Items))
+		}
+	})
+	t.Run("spend id", func(t *testing.T) {
+		transfers, _ := api.GetTransfers(&GetTransfersOptions{SpendId: "second"})
+		if len(transfers.Result.Items) != 1 || transfers.Result.Items[0].Id != 2 {
+			t.Errorf("invalid filtering by spend_id: %#v", transfers.Result.Items)
+		}
+	})
+}
+
+func TestGetTransfersOptions_QueryParams(t *testing.T) {
+	got := GetTransfersOptions{Asset: TON, TransferIds: []string{"1", "2"}, Count: 10}.QueryParams()
+	if got != "asset=TON&count=10&offset=0&transfer_ids=1%2C2" {
+		t.Errorf("unexpected query %q", got)
+	}
+}
+
+func TestPageCount(t *testing.T) {
+	for count, expected := range map[int]int{0: 0, 1: 1, 100: 0, 999: 999, 1000: 1000, 1001: 0, -1: 0} {
+		if got := pageCount(count); got != expected {
+			t.Errorf("pageCount(%d) = %d, expected %d", count, got, expected)
+		}
+	}
+	if got := (GetInvoicesOptions{Count: 1000}).QueryParams(); got != "count=1000&offset=0" {
+		t.Errorf("unexpected query %q", got)
+	}
+	if body, _ := json.Marshal(GetChecksOptions{Count: 1000}); string(body) != `{"count":1000}` {
+		t.Errorf("unexpected body %s", body)
+	}
+}
+
 func TestApiCore_GetBalance(t *testing.T) {
 	_, err := getApi().GetBalance()
 	if err != nil {
@@ -166,6 +484,86 @@ func TestApiCore_GetCurrencies(t *testing.T) {
 	}
 }
 
+func TestApiCore_CreateCheck(t *testing.T) {
+	check, err := getApi().CreateCheck(CreateCheckOptions{
+		Asset:  TON,
+		Amount: MustParseAmount("2.5"),
+	})
+	if err != nil {
+		t.Error(err)
+	}
+	if check.Result.Amount.String() != "2.5" || check.Result.Status != CheckStatusActive {
+		t.Errorf("amount(%s) != 2.5 || status(%s) != active", check.Result.Amount, check.Result.Status)
+	}
+}
+
+func TestApiCore_DeleteCheck(t *testing.T) {
+	api := getApi()
+	r, err := api.DeleteCheck(1)
+	if err != nil {
+		t.Error(err)
+	}
+	if !r.IsSuccessfully() || !r.Result {
+		t.Error("check not deleted")
+	}
+	if r, _ = api.DeleteCheck(2); r.IsSuccessfully() {
+		t.Error("deleted unknown check")
+	}
+}
+
+func TestApiCore_GetChecks(t *testing.T) {
+	api := getApi()
+	t.Run("empty params", func(t *testing.T) {
+		checks, err := api.GetChecks(nil)
+		if err != nil {
+			t.Error(err)
+		}
+		if len(checks.Result.Items) != 2 {
+			t.Errorf("count(%d) != 2", len(checks.Result.Items))
+		}
+	})
+	t.Run("status", func(t *testing.T) {
+		checks, _ := api.GetChecks(&GetChecksOptions{Status: CheckStatusActivated})
+		if len(checks.Result.Items) != 1 || checks.Result.Items[0].Id != 2 {
+			t.Errorf("invalid filtering by status: %#v", checks.Result.Items)
+		}
+	})
+}
+
+func TestCreateCheckOptions_QueryParams(t *testing.T) {
+	got := CreateCheckOptions{Asset: TON, Amount: MustParseAmount("1"), PinToUserId: 42}.QueryParams()
+	if got != "amount=1&asset=TON&pin_to_user_id=42" {
+		t.Errorf("unexpected query %q", got)
+	}
+}
+
+func TestApiCore_GetStats(t *testing.T) {
+	startAt := time.Date(2022, 5, 1, 0, 0, 0, 0, time.UTC)
+	stats, err := getApi().GetStats(&GetStatsOptions{StartAt: startAt})
+	if err != nil {
+		t.Fatal(err)
+	}
+	if !stats.Result.StartAt.Equal(startAt) {
+		t.Errorf("start_at(%s) != %s", stats.Result.StartAt, startAt)
+	}
+	if stats.Result.PaidInvoiceCount != 2 {
+		t.Errorf("paid_invoice_count(%d) != 2", stats.Result.PaidInvoiceCount)
+	}
+}
+
+func TestGetStatsOptions_QueryParams(t *testing.T) {
+	msk := time.FixedZone("MSK", 3*60*60)
+	got := GetStatsOptions{
+		StartAt: time.Date(2022, 5, 1, 3, 0, 0, 0, msk),
+	}.QueryParams()
+	if got != "start_at=2022-05-01T00%3A00%3A00Z" {
+		t.Errorf("unexpected query %q", got)
+	}
+	if got = (GetStatsOptions{}).QueryParams(); got != emptyQuery {
+		t.Errorf("unexpected query %q for empty options", got)
+	}
+}
+
 func TestEmptyToken(t *testing.T) {
 	api := getApi()
 	api.token = ""
@@ -214,6 +612,31 @@ func writeJson(rw http.ResponseWriter, code int, v interface{}, args ...interfac
 	rw.Write(data)
 }
 
+// requestValues returns params of request from query string or from JSON body.
+func requestValues(r *http.Request) url.Values {
+	values := r.URL.Query()
+	if r.Method != "POST" || r.Body == nil {
+		return values
+	}
+	body, _ := io.ReadAll(r.Body)
+	r.Body = io.NopCloser(bytes.NewReader(body))
+	var params map[string]interface{}
+	if json.Unmarshal(body, &params) != nil {
+		return values
+	}
+	for k, v := range params {
+		switch v := v.(type) {
+		case string:
+			values.Set(k, v)
+		case float64:
+			values.Set(k, strconv.FormatFloat(v, 'f', -1, 64))
+		default:
+			values.Set(k, fmt.Sprint(v))
+		}
+	}
+	return values
+}
+
 func ApiClientServer() *httptest.Server {
 	usedSpendIds := make(map[string]bool)
 	onceApiServer.Do(func() {
@@ -239,34 +662,74 @@ func ApiClientServer() *httptest.Server {
 					},
 				})
 			case "/api/createInvoice":
-				values := r.URL.Query()
-				if values.Get("asset") == "" {
+				values := requestValues(r)
+				currencyType := values.Get("currency_type")
+				if currencyType == "fiat" && values.Get("fiat") == "" {
+					writeJson(rw, 400, fmt.Sprintf(apiErrorF, 400, "invalid fiat"))
+					return
+				}
+				if currencyType != "fiat" && values.Get("asset") == "" {
 					writeJson(rw, 400, fmt.Sprintf(apiErrorF, 400, "invalid asset"))
+					return
+				}
+				var acceptedAssets []string
+				if v := values.Get("accepted_assets"); v != "" {
+					acceptedAssets = strings.Split(v, ",")
+				}
+				swap := JSON{}
+				if swapTo := values.Get("swap_to"); swapTo != "" {
+					amount, _ := strconv.ParseFloat(values.Get("amount"), 64)
+					swap = JSON{
+						"is_swapped":         true,
+						"swapped_to":         swapTo,
+						"swapped_rate":       "2",
+						"swapped_output":     strconv.FormatFloat(amount*2, 'f', -1, 64),
+						"swapped_usd_amount": strconv.FormatFloat(amount*2, 'f', -1, 64),
+					}
 				}
 				writeJson(rw, 200, JSON{
 					"ok": true,
 					"result": JSON{
-						"invoice_id":       rand.Int(),
-						"status":           "paid",
-						"hash":             "exc10sld",
-						"asset":            values.Get("asset"),
-						"amount":           values.Get("amount"),
-						"description":      values.Get("description"),
-						"payload":          values.Get("payload"),
-						"paid_btn_name":    values.Get("paid_btn_name"),
-						"paid_btn_url":     values.Get("paid_btn_url"),
-						"hidden_message":   values.Get("hidden_message"),
-						"allow_comments":   true,
-						"allow_anonymous":  true,
-						"pay_url":          "/exc10sld",
-						"created_at":       time.Now(),
-						"paid_at":          time.Now(),
-						"paid_anonymously": true,
-						"expiration_date":  "",
+						"is_swapped":         swap["is_swapped"],
+						"swapped_to":         swap["swapped_to"],
+						"swapped_rate":       swap["swapped_rate"],
+						"swapped_output":     swap["swapped_output"],
+						"swapped_usd_amount": swap["swapped_usd_amount"],
+						"invoice_id":         rand.Int(),
+						"status":             "paid",
+						"hash":               "exc10sld",
+						"currency_type":      currencyType,
+						"asset":              values.Get("asset"),
+						"fiat":               values.Get("fiat"),
+						"accepted_assets":    acceptedAssets,
+						"amount":             values.Get("amount"),
+						"description":        values.Get("description"),
+						"payload":            values.Get("payload"),
+						"paid_btn_name":      values.Get("paid_btn_name"),
+						"paid_btn_url":       values.Get("paid_btn_url"),
+						"hidden_message":     values.Get("hidden_message"),
+						"allow_comments":     true,
+						"allow_anonymous":    true,
+						"pay_url":            "/exc10sld",
+						"created_at":         time.Now(),
+						"paid_at":            time.Now(),
+						"paid_anonymously":   true,
+						"expiration_date":    "",
 					},
 				})
+			case "/api/deleteInvoice":
+				switch requestValues(r).Get("invoice_id") {
+				case "0":
+					writeJson(rw, 400, fmt.Sprintf(apiErrorF, 400, "INVOICE_ALREADY_PAID"))
+				case "1":
+					writeJson(rw, 200, JSON{"ok": true, "result": true})
+				case "5":
+					writeJson(rw, 400, fmt.Sprintf(apiErrorF, 400, "INVOICE_EXPIRED"))
+				default:
+					writeJson(rw, 400, fmt.Sprintf(apiErrorF, 400, "INVOICE_NOT_FOUND"))
+				}
 			case "/api/transfer":
-				values := r.URL.Query()
+				values := requestValues(r)
 				if values.Get("user_id") == "" {
 					writeJson(rw, 400, fmt.Sprintf(apiErrorF, 400, "invalid user id"))
 					return
@@ -335,7 +798,7 @@ func ApiClientServer() *httptest.Server {
 						"created_at": time.Now().Add(-time.Minute),
 					},
 				}
-				if r.URL.RawQuery == "" {
+				if len(requestValues(r)) == 0 {
 					writeJson(rw, 200, JSON{
 						"ok": true,
 						"result": JSON{
@@ -344,7 +807,7 @@ func ApiClientServer() *httptest.Server {
 					})
 					return
 				}
-				values := r.URL.Query()
+				values := requestValues(r)
 				filter := func(key string, source []JSON) []JSON {
 					var result []JSON
 
@@ -362,7 +825,7 @@ func ApiClientServer() *httptest.Server {
 				resultFilters := filter("status", filter("asset", invoices))
 				var resultIds []JSON
 
-				if strIds := values.Get("invoices_ids"); strIds != "" {
+				if strIds := values.Get("invoice_ids"); strIds != "" {
 					if invoicesIds := strings.Split(strIds, ","); len(invoicesIds) > 0 {
 						ids := make(map[string]struct{})
 						for _, id := range invoicesIds {
@@ -386,6 +849,44 @@ func ApiClientServer() *httptest.Server {
 						"items": resultIds,
 					},
 				})
+			case "/api/getTransfers":
+				transfers := []JSON{
+					{
+						"transfer_id":  1,
+						"spend_id":     "first",
+						"user_id":      1,
+						"asset":        "TON",
+						"amount":       "1",
+						"status":       "completed",
+						"completed_at": time.Now().Add(-time.Hour),
+					},
+					{
+						"transfer_id":  2,
+						"spend_id":     "second",
+						"user_id":      1,
+						"asset":        "USDT",
+						"amount":       "5",
+						"status":       "completed",
+						"completed_at": time.Now(),
+					},
+				}
+				values := requestValues(r)
+				var result []JSON
+				for _, v := range transfers {
+					if asset := values.Get("asset"); asset != "" && v["asset"] != asset {
+						continue
+					}
+					if spendId := values.Get("spend_id"); spendId != "" && v["spend_id"] != spendId {
+						continue
+					}
+					result = append(result, v)
+				}
+				writeJson(rw, 200, JSON{
+					"ok": true,
+					"result": JSON{
+						"items": result,
+					},
+				})
 			case "/api/getBalance":
 				writeJson(rw, 200, JSON{
 					"ok": true,
@@ -490,6 +991,89 @@ func ApiClientServer() *httptest.Server {
 						},
 					},
 				})
+			case "/api/createCheck":
+				values := requestValues(r)
+				if values.Get("asset") == "" {
+					writeJson(rw, 400, fmt.Sprintf(apiErrorF, 400, "invalid asset"))
+					return
+				}
+				writeJson(rw, 200, JSON{
+					"ok": true,
+					"result": JSON{
+						"check_id":      rand.Int(),
+						"hash":          "chk10sld",
+						"asset":         values.Get("asset"),
+						"amount":        values.Get("amount"),
+						"bot_check_url": "/chk10sld",
+						"status":        "active",
+						"created_at":    time.Now(),
+					},
+				})
+			case "/api/deleteCheck":
+				if requestValues(r).Get("check_id") != "1" {
+					writeJson(rw, 400, fmt.Sprintf(apiErrorF, 400, "CHECK_NOT_FOUND"))
+					return
+				}
+				writeJson(rw, 200, JSON{"ok": true, "result": true})
+			case "/api/getChecks":
+				checks := []JSON{
+					{
+						"check_id":   1,
+						"status":     "active",
+						"asset":      "TON",
+						"amount":     "1",
+						"created_at": time.Now(),
+					},
+					{
+						"check_id":     2,
+						"status":       "activated",
+						"asset":        "TON",
+						"amount":       "2",
+						"created_at":   time.Now().Add(-time.Hour),
+						"activated_at": time.Now(),
+					},
+				}
+				var result []JSON
+				status := requestValues(r).Get("status")
+				for _, v := range checks {
+					if status == "" || v["status"] == status {
+						result = append(result, v)
+					}
+				}
+				writeJson(rw, 200, JSON{
+					"ok": true,
+					"result": JSON{
+						"items": result,
+					},
+				})
+			case "/api/getStats":
+				values := requestValues(r)
+				endAt := time.Now().UTC()
+				if v := values.Get("end_at"); v != "" {
+					if endAt, err = time.Parse(time.RFC3339, v); err != nil {
+						writeJson(rw, 400, fmt.Sprintf(apiErrorF, 400, "END_AT_INVALID"))
+						return
+					}
+				}
+				startAt := endAt.Add(-24 * time.Hour)
+				if v := values.Get("start_at"); v != "" {
+					if startAt, err = time.Parse(time.RFC3339, v); err != nil {
+						writeJson(rw, 400, fmt.Sprintf(apiErrorF, 400, "START_AT_INVALID"))
+						return
+					}
+				}
+				writeJson(rw, 200, JSON{
+					"ok": true,
+					"result": JSON{
+						"volume":                12.5,
+						"conversion":            0.5,
+						"unique_users_count":    1,
+						"created_invoice_count": 4,
+						"paid_invoice_count":    2,
+						"start_at":              startAt,
+						"end_at":                endAt,
+					},
+				})
 			default:
 				fmt.Fprintf(rw, apiErrorF, 404, "NOT FOUND")
 			}
@@ -1,8 +1,10 @@
 package cryptopay
 
 import (
+	"context"
 	"net/http"
 	"strconv"
+	"time"
 )
 
 type (
@@ -18,8 +20,25 @@ type (
 type WebhookSettings struct {
 	// OnError is handler for error in webhook.
 	OnError func(r *http.Request, err error)
+	// OnPanic is handler for panics of asynchronous handlers. Default panics are passed to OnError.
+	OnPanic PanicHandler
 	// DefaultHandlers is set of default handlers. Default creates new set.
 	DefaultHandlers map[UpdateType][]Handler
+	// HandlerTimeout limits time of HandlerFunc handlers for update. Default no limit.
+	HandlerTimeout time.Duration
+	// Dedup is store of processed updates for deduplication of redelivered updates. Default no deduplication.
+	Dedup DedupStore
+	// MaxUpdateAge is freshness window of updates, stale updates are rejected. Default updates never become stale.
+	MaxUpdateAge time.Duration
+	// ClockSkew is allowed difference between clocks of API and server.
+	ClockSkew time.Duration
+	// Nonces is store of signatures for rejection of exact replays. Default replays aren't checked.
+	Nonces DedupStore
+	// Workers is count of workers for handlers of updates, see Dispatcher. Default goroutine per handler.
+	// Workers are stopped by Webhook.Shutdown.
+	Workers int
+	// QueueSize is size of queue of every worker. Full queue makes webhook respond with 503 status code.
+	QueueSize int
 }
 
 // ClientSettings for easy configure NewClient.
@@ -30,6 +49,14 @@ type ClientSettings struct {
 	ApiHost string
 	// HttpClient for make requests. Default http.DefaultClient.
 	HttpClient *http.Client
+	// Retry is policy of retries for transient failures. Default requests aren't retried.
+	// For recommended values use DefaultRetryPolicy.
+	Retry RetryPolicy
+	// RateLimit is budgets of client-side rate limiter. Default requests aren't limited,
+	// but requests are paused after 429 response for Retry-After duration.
+	RateLimit RateLimitSettings
+	// RequestFormat is way of passing params to API. Default FormatJSON.
+	RequestFormat RequestFormat
 	// Webhook settings. If set default value webhook can correct work.
 	Webhook WebhookSettings
 }
@@ -39,6 +66,9 @@ type ClientSettings struct {
 // Methods that call API and return error can return ApiError.
 // For get ApiError use GetApiError.
 //
+// Options are checked by their Validate method before request. Invalid options return ValidationError
+// without request to API.
+//
 // If you want set regular params in opt parameter - set regular parameters default value (empty string for Asset & string, 0 for numbers).
 type Client struct {
 	// api is ApiCore instance for request to API.
@@ -62,8 +92,21 @@ func NewClient(settings ClientSettings) *Client {
 		apiHost = MainNetHost
 	}
 	api := NewApi(settings.Token, apiHost, httpClient)
+	api.SetRetryPolicy(settings.Retry)
+	api.SetRateLimit(settings.RateLimit)
+	api.SetRequestFormat(settings.RequestFormat)
 
 	w := NewWebhook(settings.Token, settings.Webhook.DefaultHandlers, settings.Webhook.OnError)
+	w.OnPanic = settings.Webhook.OnPanic
+	w.HandlerTimeout = settings.Webhook.HandlerTimeout
+	w.Dedup = settings.Webhook.Dedup
+	w.MaxUpdateAge = settings.Webhook.MaxUpdateAge
+	w.ClockSkew = settings.Webhook.ClockSkew
+	w.Nonces = settings.Webhook.Nonces
+	if settings.Webhook.Workers > 0 {
+		w.Dispatcher = NewDispatcher(settings.Webhook.Workers, settings.Webhook.QueueSize)
+		w.ownsDispatcher = true
+	}
 	return &Client{
 		api: api,
 		w:   w,
@@ -74,11 +117,16 @@ func NewClient(settings ClientSettings) *Client {
 func (c Client) Api() ApiCore { return *c.api }
 
 // Webhook return instance of Webhook
-func (c Client) Webhook() Webhook { return *c.w }
+func (c Client) Webhook() *Webhook { return c.w }
 
 // GetMe is representation of api/getMe.
 func (c *Client) GetMe() (*AppInfo, error) {
-	app, err := c.api.GetMe()
+	return c.GetMeCtx(context.Background())
+}
+
+// GetMeCtx is representation of api/getMe with context.
+func (c *Client) GetMeCtx(ctx context.Context) (*AppInfo, error) {
+	app, err := c.api.GetMeCtx(ctx)
 	if err != nil {
 		return nil, err
 	}
@@ -90,14 +138,24 @@ func (c *Client) GetMe() (*AppInfo, error) {
 
 // CreateInvoice is representation for api/createInvoice.
 //
-func (c *Client) CreateInvoice(asset Asset, amount float64, opt CreateInvoiceOptions) (*Invoice, error) {
+// For fiat invoice set asset as empty string and fill CurrencyType and Fiat in opt.
+// Options are validated before request, mixing of crypto and fiat fields matches ErrorCurrencyMismatch.
+func (c *Client) CreateInvoice(asset Asset, amount Amount, opt CreateInvoiceOptions) (*Invoice, error) {
+	return c.CreateInvoiceCtx(context.Background(), asset, amount, opt)
+}
+
+// CreateInvoiceCtx is representation for api/createInvoice with context.
+func (c *Client) CreateInvoiceCtx(ctx context.Context, asset Asset, amount Amount, opt CreateInvoiceOptions) (*Invoice, error) {
 	if asset != "" {
 		opt.Asset = asset
 	}
-	if amount != 0 {
-		opt.Amount = strconv.FormatFloat(amount, 'f', -1, 64)
+	if !amount.IsZero() {
+		opt.Amount = amount
+	}
+	if err := opt.Validate(); err != nil {
+		return nil, err
 	}
-	invoice, err := c.api.CreateInvoice(opt)
+	invoice, err := c.api.CreateInvoiceCtx(ctx, opt)
 	if err != nil {
 		return nil, err
 	}
@@ -107,11 +165,46 @@ func (c *Client) CreateInvoice(asset Asset, amount float64, opt CreateInvoiceOpt
 	return invoice.Result, nil
 }
 
+// invoiceStateErrors maps names of API errors to status of the invoice which caused it.
+var invoiceStateErrors = map[string]InvoiceStatus{
+	ErrInvoiceAlreadyPaid.Name: StatusPaid,
+	ErrInvoiceExpired.Name:     StatusExpired,
+}
+
+// DeleteInvoice is representation for api/deleteInvoice.
+//
+// Only active invoice can be deleted. If invoice already paid or expired returns InvoiceStateError.
+func (c *Client) DeleteInvoice(invoiceId int) error {
+	return c.DeleteInvoiceCtx(context.Background(), invoiceId)
+}
+
+// DeleteInvoiceCtx is representation for api/deleteInvoice with context. See DeleteInvoice for errors.
+func (c *Client) DeleteInvoiceCtx(ctx context.Context, invoiceId int) error {
+	deleted, err := c.api.DeleteInvoiceCtx(ctx, invoiceId)
+	if err != nil {
+		return err
+	}
+	if !deleted.IsSuccessfully() {
+		if deleted.Error != nil {
+			if status, ok := invoiceStateErrors[deleted.Error.Name]; ok {
+				return &InvoiceStateError{InvoiceId: invoiceId, Status: status, Err: deleted.Error}
+			}
+		}
+		return deleted.Error
+	}
+	return nil
+}
+
 // DoTransfer is representation for api/transfer. Error regular or ApiError.
 //
 // If you want set regular params in opt - set regular parameters default value (empty string for Asset & string, 0 for numbers)
 // spendId must be unique for every operation.
-func (c *Client) DoTransfer(userId int, asset Asset, amount float64, spendId string, opt DoTransferOptions) (*Transfer, error) {
+func (c *Client) DoTransfer(userId int, asset Asset, amount Amount, spendId string, opt DoTransferOptions) (*Transfer, error) {
+	return c.DoTransferCtx(context.Background(), userId, asset, amount, spendId, opt)
+}
+
+// DoTransferCtx is representation for api/transfer with context. See DoTransfer for parameters.
+func (c *Client) DoTransferCtx(ctx context.Context, userId int, asset Asset, amount Amount, spendId string, opt DoTransferOptions) (*Transfer, error) {
 	if userId != 0 {
 		opt.UserId = userId
 	}
@@ -121,10 +214,13 @@ func (c *Client) DoTransfer(userId int, asset Asset, amount float64, spendId str
 	if spendId != "" {
 		opt.SpendId = spendId
 	}
-	if amount != 0 {
-		opt.Amount = strconv.FormatFloat(amount, 'f', -1, 64)
+	if !amount.IsZero() {
+		opt.Amount = amount
 	}
-	transfer, err := c.api.DoTransfer(opt)
+	if err := opt.Validate(); err != nil {
+		return nil, err
+	}
+	transfer, err := c.api.DoTransferCtx(ctx, opt)
 	if err != nil {
 		return nil, err
 	}
@@ -137,7 +233,18 @@ func (c *Client) DoTransfer(userId int, asset Asset, amount float64, spendId str
 // GetInvoices is representation for api/getInvoices.
 // Set opt parameter as nil for empty API params.
 func (c *Client) GetInvoices(opt *GetInvoicesOptions) ([]Invoice, error) {
-	invoices, err := c.api.GetInvoices(opt)
+	return c.GetInvoicesCtx(context.Background(), opt)
+}
+
+// GetInvoicesCtx is representation for api/getInvoices with context.
+// Set opt parameter as nil for empty API params.
+func (c *Client) GetInvoicesCtx(ctx context.Context, opt *GetInvoicesOptions) ([]Invoice, error) {
+	if opt != nil {
+		if err := opt.Validate(); err != nil {
+			return nil, err
+		}
+	}
+	invoices, err := c.api.GetInvoicesCtx(ctx, opt)
 	if err != nil {
 		return nil, err
 	}
@@ -147,9 +254,38 @@ func (c *Client) GetInvoices(opt *GetInvoicesOptions) ([]Invoice, error) {
 	return invoices.Result.Items, nil
 }
 
+// GetTransfers is representation for api/getTransfers.
+// Set opt parameter as nil for empty API params.
+func (c *Client) GetTransfers(opt *GetTransfersOptions) ([]Transfer, error) {
+	return c.GetTransfersCtx(context.Background(), opt)
+}
+
+// GetTransfersCtx is representation for api/getTransfers with context.
+// Set opt parameter as nil for empty API params.
+func (c *Client) GetTransfersCtx(ctx context.Context, opt *GetTransfersOptions) ([]Transfer, error) {
+	if opt != nil {
+		if err := opt.Validate(); err != nil {
+			return nil, err
+		}
+	}
+	transfers, err := c.api.GetTransfersCtx(ctx, opt)
+	if err != nil {
+		return nil, err
+	}
+	if !transfers.IsSuccessfully() {
+		return nil, transfers.Error
+	}
+	return transfers.Result.Items, nil
+}
+
 // GetBalance is representation for api/getBalance.
 func (c *Client) GetBalance() (BalanceInfo, error) {
-	balance, err := c.api.GetBalance()
+	return c.GetBalanceCtx(context.Background())
+}
+
+// GetBalanceCtx is representation for api/getBalance with context.
+func (c *Client) GetBalanceCtx(ctx context.Context) (BalanceInfo, error) {
+	balance, err := c.api.GetBalanceCtx(ctx)
 	if err != nil {
 		return nil, err
 	}
@@ -161,7 +297,12 @@ func (c *Client) GetBalance() (BalanceInfo, error) {
 
 // GetExchangeRates is representation for api/getExchangeRates.
 func (c *Client) GetExchangeRates() (ExchangeRateArray, error) {
-	exchangeInfo, err := c.api.GetExchangeRates()
+	return c.GetExchangeRatesCtx(context.Background())
+}
+
+// GetExchangeRatesCtx is representation for api/getExchangeRates with context.
+func (c *Client) GetExchangeRatesCtx(ctx context.Context) (ExchangeRateArray, error) {
+	exchangeInfo, err := c.api.GetExchangeRatesCtx(ctx)
 	if err != nil {
 		return nil, err
 	}
@@ -173,7 +314,12 @@ func (c *Client) GetExchangeRates() (ExchangeRateArray, error) {
 
 // GetCurrencies is representation for api/getCurrencies.
 func (c *Client) GetCurrencies() (CurrencyInfoArray, error) {
-	currencies, err := c.api.GetCurrencies()
+	return c.GetCurrenciesCtx(context.Background())
+}
+
+// GetCurrenciesCtx is representation for api/getCurrencies with context.
+func (c *Client) GetCurrenciesCtx(ctx context.Context) (CurrencyInfoArray, error) {
+	currencies, err := c.api.GetCurrenciesCtx(ctx)
 	if err != nil {
 		return nil, err
 	}
@@ -183,16 +329,124 @@ func (c *Client) GetCurrencies() (CurrencyInfoArray, error) {
 	return currencies.Result, nil
 }
 
-// On alias for Webhook.Bind. Add handler to slice for given update type. Return index of new handler
-func (c *Client) On(updateType UpdateType, handler Handler) int {
+// CreateCheck is representation for api/createCheck.
+//
+// Check can be pinned either to PinToUserId or to PinToUsername, otherwise error matches ErrorCheckPinConflict.
+func (c *Client) CreateCheck(asset Asset, amount Amount, opt CreateCheckOptions) (*Check, error) {
+	return c.CreateCheckCtx(context.Background(), asset, amount, opt)
+}
+
+// CreateCheckCtx is representation for api/createCheck with context. See CreateCheck for parameters.
+func (c *Client) CreateCheckCtx(ctx context.Context, asset Asset, amount Amount, opt CreateCheckOptions) (*Check, error) {
+	if asset != "" {
+		opt.Asset = asset
+	}
+	if !amount.IsZero() {
+		opt.Amount = amount
+	}
+	if err := opt.Validate(); err != nil {
+		return nil, err
+	}
+	check, err := c.api.CreateCheckCtx(ctx, opt)
+	if err != nil {
+		return nil, err
+	}
+	if !check.IsSuccessfully() {
+		return nil, check.Error
+	}
+	return check.Result, nil
+}
+
+// DeleteCheck is representation for api/deleteCheck.
+func (c *Client) DeleteCheck(checkId int) error {
+	return c.DeleteCheckCtx(context.Background(), checkId)
+}
+
+// DeleteCheckCtx is representation for api/deleteCheck with context.
+func (c *Client) DeleteCheckCtx(ctx context.Context, checkId int) error {
+	deleted, err := c.api.DeleteCheckCtx(ctx, checkId)
+	if err != nil {
+		return err
+	}
+	if !deleted.IsSuccessfully() {
+		return deleted.Error
+	}
+	return nil
+}
+
+// GetChecks is representation for api/getChecks.
+// Set opt parameter as nil for empty API params.
+func (c *Client) GetChecks(opt *GetChecksOptions) ([]Check, error) {
+	return c.GetChecksCtx(context.Background(), opt)
+}
+
+// GetChecksCtx is representation for api/getChecks with context.
+// Set opt parameter as nil for empty API params.
+func (c *Client) GetChecksCtx(ctx context.Context, opt *GetChecksOptions) ([]Check, error) {
+	if opt != nil {
+		if err := opt.Validate(); err != nil {
+			return nil, err
+		}
+	}
+	checks, err := c.api.GetChecksCtx(ctx, opt)
+	if err != nil {
+		return nil, err
+	}
+	if !checks.IsSuccessfully() {
+		return nil, checks.Error
+	}
+	return checks.Result.Items, nil
+}
+
+// GetStats is representation for api/getStats.
+// Set opt parameter as nil for statistics of last 24 hours.
+func (c *Client) GetStats(opt *GetStatsOptions) (*AppStats, error) {
+	return c.GetStatsCtx(context.Background(), opt)
+}
+
+// GetStatsCtx is representation for api/getStats with context.
+// Set opt parameter as nil for statistics of last 24 hours.
+func (c *Client) GetStatsCtx(ctx context.Context, opt *GetStatsOptions) (*AppStats, error) {
+	if opt != nil {
+		if err := opt.Validate(); err != nil {
+			return nil, err
+		}
+	}
+	stats, err := c.api.GetStatsCtx(ctx, opt)
+	if err != nil {
+		return nil, err
+	}
+	if !stats.IsSuccessfully() {
+		return nil, stats.Error
+	}
+	return stats.Result, nil
+}
+
+// On alias for Webhook.Bind. Add handler for given update type. Return ID of new handler
+func (c *Client) On(updateType UpdateType, handler Handler) HandlerID {
 	return c.w.Bind(updateType, handler)
 }
 
 // OnInvoicePaid is shortcut for Client.On with update type "invoice_paid".
-func (c *Client) OnInvoicePaid(handler Handler) int {
+func (c *Client) OnInvoicePaid(handler Handler) HandlerID {
 	return c.w.Bind(UpdateInvoicePaid, handler)
 }
 
+// Handle alias for Webhook.Handle. Add synchronous handler for given update type. Return ID of new handler
+func (c *Client) Handle(updateType UpdateType, fn HandlerFunc) HandlerID {
+	return c.w.Handle(updateType, fn)
+}
+
+// HandleInvoicePaid is shortcut for Client.Handle with update type "invoice_paid".
+func (c *Client) HandleInvoicePaid(fn HandlerFunc) HandlerID {
+	return c.w.Handle(UpdateInvoicePaid, fn)
+}
+
+// Use alias for Webhook.Use. Add middlewares to webhook.
+func (c *Client) Use(middlewares ...Middleware) {
+	c.w.Use(middlewares...)
+}
+
 // DeleteAllHandlersFor alias for Webhook.DeleteHandlers.
 //
 // Delete all handlers for given update type.
@@ -201,20 +455,14 @@ func (c *Client) DeleteAllHandlersFor(updateType UpdateType) {
 	c.w.DeleteHandlers(updateType)
 }
 
-func (c *Client) DeleteHandler(updateType UpdateType, i int) {
-	c.w.DeleteHandlerByIndex(updateType, i)
+// DeleteHandler alias for Webhook.DeleteHandler. Delete handler with given ID.
+func (c *Client) DeleteHandler(id HandlerID) bool {
+	return c.w.DeleteHandler(id)
 }
 
-// Once add handler that will call once.
-func (c *Client) Once(updateType UpdateType, handler Handler) {
-	// Index for adding handler
-	i := len(c.w.handlers[updateType])
-
-	c.w.Bind(updateType, func(update *WebhookUpdate) {
-		handler(update)
-		a := c.w.handlers[updateType]
-		c.w.handlers[updateType] = append(a[:i], a[i+1:]...)
-	})
+// Once alias for Webhook.Once. Add handler that will call once.
+func (c *Client) Once(updateType UpdateType, handler Handler) HandlerID {
+	return c.w.Once(updateType, handler)
 }
 
 // IsSuccessfully indicates whether API request success.
@@ -226,6 +474,16 @@ func (r BaseApiResponse) IsSuccessfully() bool {
 // key - currency code (Asset), value - balance for Asset as string
 func (b BalanceInfo) AsMap() map[Asset]string {
 	balances := make(map[Asset]string)
+	for _, currency := range b {
+		balances[currency.CurrencyCode] = currency.Available.String()
+	}
+	return balances
+}
+
+// AsMapAmount returns transformed BalanceInfo ([]BalanceCurrency) into map,
+// key - currency code (Asset), value - exact balance for Asset as Amount
+func (b BalanceInfo) AsMapAmount() map[Asset]Amount {
+	balances := make(map[Asset]Amount)
 	for _, currency := range b {
 		balances[currency.CurrencyCode] = currency.Available
 	}
@@ -233,11 +491,12 @@ func (b BalanceInfo) AsMap() map[Asset]string {
 }
 
 // AsMapFloat returns transformed BalanceInfo ([]BalanceCurrency) into map,
-// key - currency code (Asset), value - balance for Asset as float64
+// key - currency code (Asset), value - balance for Asset as float64.
+// Returns error if balance is out of float64 range. For exact values use AsMapAmount.
 func (b BalanceInfo) AsMapFloat() (map[Asset]float64, error) {
 	balances := make(map[Asset]float64)
 	for _, currency := range b {
-		balance, err := strconv.ParseFloat(currency.Available, 64)
+		balance, err := strconv.ParseFloat(currency.Available.String(), 64)
 		if err != nil {
 			return nil, err
 		}
@@ -272,10 +531,10 @@ func (e ExchangeRateArray) AsMap() map[RatesKey]ExchangeRate {
 }
 
 // Get returns exchange rate of target currency in source currency and the success indicator.
-func (e ExchangeRateArray) Get(source, target Asset) (string, bool) {
+func (e ExchangeRateArray) Get(source, target Asset) (Amount, bool) {
 	rate, ok := e.AsMap()[RatesKey{source, target}]
 	if !ok || !rate.IsValid {
-		return "", false
+		return Amount{}, false
 	}
 	return rate.Rate, true
 }
@@ -1,9 +1,12 @@
 package cryptopay
 
 import (
+	"errors"
 	"net/http"
+	"net/http/httptest"
 	"reflect"
 	"testing"
+	"time"
 )
 
 func getClient() *Client {
@@ -46,7 +49,7 @@ func TestClient_Alias(t *testing.T) {
 	if !reflect.DeepEqual(c.Api(), a) {
 		t.Error("api instances not equals")
 	}
-	if !reflect.DeepEqual(c.Webhook(), w) {
+	if c.Webhook() != &w {
 		t.Error("webhook instances not equals")
 	}
 }
@@ -65,39 +68,159 @@ func TestClient_GetMe(t *testing.T) {
 func TestClient_CreateInvoice(t *testing.T) {
 	c := getClient()
 	t.Run("with params usage", func(t *testing.T) {
-		_, err := c.CreateInvoice(BTC, 3.14, CreateInvoiceOptions{})
+		_, err := c.CreateInvoice(BTC, MustParseAmount("3.14"), CreateInvoiceOptions{})
 		if err != nil {
 			t.Error(err)
 		}
 	})
 	t.Run("with opt param usage", func(t *testing.T) {
-		_, err := c.CreateInvoice("", 0, CreateInvoiceOptions{
+		_, err := c.CreateInvoice("", Amount{}, CreateInvoiceOptions{
 			Asset:  ETH,
-			Amount: "3.14",
+			Amount: MustParseAmount("3.14"),
 		})
 		if err != nil {
 			t.Error(err)
 		}
 	})
-	_, err := c.CreateInvoice("", 0, CreateInvoiceOptions{})
-	if err == nil {
-		t.Error()
+	t.Run("fiat", func(t *testing.T) {
+		invoice, err := c.CreateInvoice("", NewAmount(5, 0), CreateInvoiceOptions{
+			CurrencyType: CurrencyFiat,
+			Fiat:         USD,
+		})
+		if err != nil {
+			t.Fatal(err)
+		}
+		if invoice.Fiat != USD {
+			t.Errorf("fiat(%s) != USD", invoice.Fiat)
+		}
+	})
+	t.Run("mixed currency", func(t *testing.T) {
+		_, err := c.CreateInvoice(TON, NewAmount(5, 0), CreateInvoiceOptions{
+			CurrencyType: CurrencyFiat,
+			Fiat:         USD,
+		})
+		if !errors.Is(err, ErrorCurrencyMismatch) {
+			t.Errorf("err(%v) != ErrorCurrencyMismatch", err)
+		}
+	})
+	_, err := c.CreateInvoice("", Amount{}, CreateInvoiceOptions{})
+	if GetValidationError(err) == nil {
+		t.Errorf("err(%v) is not ValidationError", err)
+	}
+	for field, opt := range map[string]CreateInvoiceOptions{
+		"Fiat":         {CurrencyType: CurrencyFiat},
+		"CurrencyType": {CurrencyType: "barter"},
+	} {
+		_, err := c.CreateInvoice("", NewAmount(5, 0), opt)
+		if validationErr := GetValidationError(err); validationErr == nil || !validationErr.Has(field) {
+			t.Errorf("err(%v) doesn't contain field %s", err, field)
+		}
+		if !IsClientError(err) {
+			t.Errorf("IsClientError(%v) = false", err)
+		}
+	}
+}
+
+func TestClient_DeleteInvoice(t *testing.T) {
+	c := getClient()
+	if err := c.DeleteInvoice(1); err != nil {
+		t.Error(err)
+	}
+	var cases = []struct {
+		name     string
+		id       int
+		expected InvoiceStatus
+	}{
+		{name: "paid", id: 0, expected: StatusPaid},
+		{name: "expired", id: 5, expected: StatusExpired},
+	}
+	for _, tc := range cases {
+		t.Run(tc.name, func(t *testing.T) {
+			err := c.DeleteInvoice(tc.id)
+			var stateErr *InvoiceStateError
+			if !errors.As(err, &stateErr) {
+				t.Fatalf("err(%v) is not InvoiceStateError", err)
+			}
+			if stateErr.Status != tc.expected || stateErr.InvoiceId != tc.id {
+				t.Errorf("unexpected error %#v", stateErr)
+			}
+			if GetApiError(err) == nil {
+				t.Error("ApiError not unwrapped")
+			}
+		})
+	}
+	if err := c.DeleteInvoice(42); GetApiError(err) == nil || errors.As(err, new(*InvoiceStateError)) {
+		t.Errorf("unexpected error %v", err)
 	}
 }
 
 func TestClient_DoTransfer(t *testing.T) {
 	c := getClient()
-	_, err := c.DoTransfer(1, BTC, 1, "0", DoTransferOptions{})
+	_, err := c.DoTransfer(1, BTC, NewAmount(1, 0), "0", DoTransferOptions{})
 	if err != nil {
 		t.Error(err)
 	}
-	_, err = c.DoTransfer(1, BTC, 1, "0", DoTransferOptions{})
+	_, err = c.DoTransfer(1, BTC, NewAmount(1, 0), "0", DoTransferOptions{})
 	if err == nil {
 		t.Error("not unique spend_id")
 	}
-	_, err = c.DoTransfer(0, "", 0, "", DoTransferOptions{})
-	if err == nil {
-		t.Error("pass empty values")
+	_, err = c.DoTransfer(0, "", Amount{}, "", DoTransferOptions{})
+	if GetValidationError(err) == nil {
+		t.Errorf("pass empty values, err(%v)", err)
+	}
+}
+
+func TestClient_CreateCheck(t *testing.T) {
+	c := getClient()
+	check, err := c.CreateCheck(TON, MustParseAmount("1.5"), CreateCheckOptions{PinToUsername: "durov"})
+	if err != nil {
+		t.Error(err)
+	}
+	if check.Amount.String() != "1.5" {
+		t.Errorf("amount(%s) != 1.5", check.Amount)
+	}
+	_, err = c.CreateCheck(TON, NewAmount(1, 0), CreateCheckOptions{PinToUserId: 1, PinToUsername: "durov"})
+	if !errors.Is(err, ErrorCheckPinConflict) {
+		t.Errorf("err(%v) != ErrorCheckPinConflict", err)
+	}
+}
+
+func TestClient_DeleteCheck(t *testing.T) {
+	c := getClient()
+	if err := c.DeleteCheck(1); err != nil {
+		t.Error(err)
+	}
+	if err := c.DeleteCheck(3); GetApiError(err) == nil {
+		t.Errorf("err(%v) is not ApiError", err)
+	}
+}
+
+func TestClient_Validate(t *testing.T) {
+	var calls int
+	s := httptest.NewServer(http.HandlerFunc(func(rw http.ResponseWriter, r *http.Request) {
+		calls++
+	}))
+	defer s.Close()
+	c := NewClient(ClientSettings{Token: "1:test_token", ApiHost: s.URL, HttpClient: s.Client()})
+	_, err := c.CreateInvoice(TON, NewAmount(1, 0), CreateInvoiceOptions{ExpiresIn: -1, PaidButtonUrl: "t.me/bot"})
+	if validationErr := GetValidationError(err); validationErr == nil || len(validationErr.Errors) != 2 {
+		t.Errorf("unexpected error %v", err)
+	}
+	if _, err := c.GetChecks(&GetChecksOptions{Count: 5000}); GetValidationError(err) == nil {
+		t.Errorf("unexpected error %v", err)
+	}
+	if calls != 0 {
+		t.Errorf("invalid options sent to API %d times", calls)
+	}
+}
+
+func TestClient_GetStats(t *testing.T) {
+	stats, err := getClient().GetStats(nil)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if stats.Volume.String() != "12.5" || stats.EndAt.Sub(stats.StartAt) != 24*time.Hour {
+		t.Errorf("unexpected stats %#v", stats)
 	}
 }
 
@@ -121,22 +244,22 @@ func TestClient_HandlerBinding(t *testing.T) {
 	c.On("test", func(_ *WebhookUpdate) {
 		handledType = "test_2"
 	})
-	c.w.handlers["test"][0](nil)
+	c.w.handlersFor("test")[0](nil)
 	if handledType != "test_1" {
 		t.Error("handler test_1 did not work")
 	}
-	c.w.handlers["test"][1](nil)
+	c.w.handlersFor("test")[1](nil)
 	if handledType != "test_2" {
 		t.Error("handler test_2 did not work")
 	}
 	i := c.OnInvoicePaid(func(_ *WebhookUpdate) {
 		handledType = UpdateInvoicePaid
 	})
-	c.w.handlers[UpdateInvoicePaid][0](nil)
+	c.w.handlersFor(UpdateInvoicePaid)[0](nil)
 	if handledType != UpdateInvoicePaid {
 		t.Error("handler OnInvoicePaid did not work")
 	}
-	c.DeleteHandler(UpdateInvoicePaid, i)
+	c.DeleteHandler(i)
 	if len(c.w.handlers[UpdateInvoicePaid]) != 0 {
 		t.Error("invoice_paid not empty")
 	}
@@ -153,7 +276,7 @@ func TestClient_Once(t *testing.T) {
 	c.Once("test", func(_ *WebhookUpdate) {
 		result = true
 	})
-	c.w.handlers["test"][0](nil)
+	c.w.handlersFor("test")[0](nil)
 	if !result {
 		t.Error("handler did not work")
 	}
@@ -166,15 +289,15 @@ func TestBalanceInfo(t *testing.T) {
 	var balance BalanceInfo = []BalanceCurrency{
 		{
 			CurrencyCode: "test",
-			Available:    "1",
+			Available:    MustParseAmount("1"),
 		},
 		{
 			CurrencyCode: BTC,
-			Available:    "100",
+			Available:    MustParseAmount("100"),
 		},
 		{
 			"err",
-			"string",
+			MustParseAmount("1e400"),
 		},
 	}
 	if balance.AsMap()[BTC] != "100" {
@@ -183,7 +306,10 @@ func TestBalanceInfo(t *testing.T) {
 	if _, err := balance.AsMapFloat(); err == nil {
 		t.Error("invalid float parsing")
 	}
-	balance[2].Available = "3.14"
+	if !balance.AsMapAmount()["err"].Equal(MustParseAmount("1e400")) {
+		t.Error("invalid exact map")
+	}
+	balance[2].Available = MustParseAmount("3.14")
 	m, err := balance.AsMapFloat()
 	if err != nil {
 		t.Error(err)
@@ -217,34 +343,34 @@ func TestExchangeRatesInfo(t *testing.T) {
 			IsValid: true,
 			Source:  BTC,
 			Target:  "USD",
-			Rate:    "40000.12",
+			Rate:    MustParseAmount("40000.12"),
 		},
 		{
 			IsValid: true,
 			Source:  BTC,
 			Target:  "EUR",
-			Rate:    "33468",
+			Rate:    MustParseAmount("33468"),
 		},
 		{
 			IsValid: true,
 			Source:  ETH,
 			Target:  "USD",
-			Rate:    "2604.14",
+			Rate:    MustParseAmount("2604.14"),
 		},
 		{
 			IsValid: true,
 			Source:  ETH,
 			Target:  "EUR",
-			Rate:    "2297",
+			Rate:    MustParseAmount("2297"),
 		},
 		{
 			IsValid: false,
 			Source:  ETH,
 			Target:  BTC,
-			Rate:    "0",
+			Rate:    MustParseAmount("0"),
 		},
 	}
-	if exchangeRates.AsMap()[RatesKey{ETH, "USD"}].Rate != "2604.14" {
+	if exchangeRates.AsMap()[RatesKey{ETH, "USD"}].Rate.String() != "2604.14" {
 		t.Error("invalid AsMap()")
 	}
 	if _, ok := exchangeRates.Get("test", "invalid"); ok {
@@ -253,7 +379,7 @@ func TestExchangeRatesInfo(t *testing.T) {
 	if _, ok := exchangeRates.Get(ETH, BTC); ok {
 		t.Error("invalid filtering Get()")
 	}
-	if v, ok := exchangeRates.Get(BTC, "USD"); !ok || v != "40000.12" {
+	if v, ok := exchangeRates.Get(BTC, "USD"); !ok || v.String() != "40000.12" {
 		t.Errorf("invalid Get() ok=%v, v=%v", ok, v)
 	}
 }
@@ -0,0 +1,522 @@
+package cryptopaytest
+
+import (
+	"encoding/json"
+	"net/http"
+	"sort"
+	"strconv"
+	"strings"
+	"time"
+
+	"github.com/vitaliy-ukiru/go-cryptopay"
+)
+
+const (
+	defaultPageCount = 100
+	maxPageCount     = 1000
+	maxExpiresIn     = 2678400
+)
+
+// method is handler of API method. Handlers are called with locked mu.
+type method func(s *Server, p params) (interface{}, *cryptopay.ApiError)
+
+var methods = map[string]method{
+	"getMe":            (*Server).getMe,
+	"createInvoice":    (*Server).createInvoice,
+	"deleteInvoice":    (*Server).deleteInvoice,
+	"transfer":         (*Server).transfer,
+	"getInvoices":      (*Server).getInvoices,
+	"getTransfers":     (*Server).getTransfers,
+	"getBalance":       (*Server).getBalance,
+	"getExchangeRates": (*Server).getExchangeRates,
+	"getCurrencies":    (*Server).getCurrencies,
+	"createCheck":      (*Server).createCheck,
+	"deleteCheck":      (*Server).deleteCheck,
+	"getChecks":        (*Server).getChecks,
+	"getStats":         (*Server).getStats,
+}
+
+// currencies is result of getCurrencies.
+var currencies = []cryptopay.CurrencyInfo{
+	{IsBlockchain: true, Name: "Bitcoin", Code: cryptopay.BTC, Decimals: 8},
+	{IsBlockchain: true, Name: "Toncoin", Code: cryptopay.TON, Decimals: 9},
+	{IsBlockchain: true, Name: "Ethereum", Code: cryptopay.ETH, Decimals: 18},
+	{IsBlockchain: true, IsStablecoin: true, Name: "Tether", Code: cryptopay.USDT, Decimals: 6},
+	{IsBlockchain: true, IsStablecoin: true, Name: "USD Coin", Code: cryptopay.USDC, Decimals: 6},
+	{IsBlockchain: true, IsStablecoin: true, Name: "Binance USD", Code: cryptopay.BUSD, Decimals: 18},
+	{IsFiat: true, Name: "United States dollar", Code: cryptopay.Asset(cryptopay.USD), Decimals: 8},
+}
+
+func apiError(code int, name string) *cryptopay.ApiError {
+	return &cryptopay.ApiError{Code: code, Name: name}
+}
+
+func invalid(name string) *cryptopay.ApiError {
+	return apiError(http.StatusBadRequest, strings.ToUpper(name)+"_INVALID")
+}
+
+// serveHTTP checks token, decodes params from query or JSON body and calls handler of method.
+func (s *Server) serveHTTP(rw http.ResponseWriter, r *http.Request) {
+	handler, ok := methods[strings.TrimPrefix(r.URL.Path, "/api/")]
+	if !ok {
+		writeError(rw, cryptopay.ErrMethodNotFound)
+		return
+	}
+	if r.Header.Get("Crypto-Pay-API-Token") != s.token {
+		writeError(rw, cryptopay.ErrUnauthorized)
+		return
+	}
+	p, err := readParams(r)
+	if err != nil {
+		writeError(rw, invalid("params"))
+		return
+	}
+
+	s.mu.Lock()
+	result, apiErr := handler(s, p)
+	s.mu.Unlock()
+
+	if apiErr != nil {
+		writeError(rw, apiErr)
+		return
+	}
+	writeJson(rw, http.StatusOK, map[string]interface{}{"ok": true, "result": result})
+}
+
+func writeError(rw http.ResponseWriter, err *cryptopay.ApiError) {
+	writeJson(rw, err.Code, map[string]interface{}{"ok": false, "error": err})
+}
+
+func writeJson(rw http.ResponseWriter, code int, v interface{}) {
+	rw.Header().Set("Content-Type", "application/json")
+	rw.WriteHeader(code)
+	json.NewEncoder(rw).Encode(v)
+}
+
+// params of API method.
+type params map[string]string
+
+// readParams merges query params and params from JSON body.
+func readParams(r *http.Request) (params, error) {
+	p := make(params)
+	for k, v := range r.URL.Query() {
+		p[k] = v[0]
+	}
+	if r.Body == nil {
+		return p, nil
+	}
+	var body map[string]interface{}
+	dec := json.NewDecoder(r.Body)
+	dec.UseNumber()
+	if err := dec.Decode(&body); err != nil {
+		if r.ContentLength > 0 || r.Header.Get("Content-Type") == "application/json" {
+			return nil, err
+		}
+		return p, nil
+	}
+	for k, v := range body {
+		switch v := v.(type) {
+		case string:
+			p[k] = v
+		case json.Number:
+			p[k] = v.String()
+		case bool:
+			p[k] = strconv.FormatBool(v)
+		}
+	}
+	return p, nil
+}
+
+func (p params) int(name string) (int, *cryptopay.ApiError) {
+	if p[name] == "" {
+		return 0, nil
+	}
+	v, err := strconv.Atoi(p[name])
+	if err != nil {
+		return 0, invalid(name)
+	}
+	return v, nil
+}
+
+func (p params) bool(name string, def bool) (bool, *cryptopay.ApiError) {
+	if p[name] == "" {
+		return def, nil
+	}
+	v, err := strconv.ParseBool(p[name])
+	if err != nil {
+		return false, invalid(name)
+	}
+	return v, nil
+}
+
+// amount returns required positive amount.
+func (p params) amount(name string) (cryptopay.Amount, *cryptopay.ApiError) {
+	v, err := cryptopay.ParseAmount(p[name])
+	if err != nil {
+		return cryptopay.Amount{}, invalid(name)
+	}
+	if v.Sign() <= 0 {
+		return cryptopay.Amount{}, cryptopay.ErrAmountTooSmall
+	}
+	return v, nil
+}
+
+// asset returns required currency code.
+func (p params) asset(name string) (cryptopay.Asset, *cryptopay.ApiError) {
+	if p[name] == "" {
+		return "", invalid(name)
+	}
+	return cryptopay.Asset(p[name]), nil
+}
+
+// ids returns set of comma separated IDs.
+func (p params) ids(name string) (map[int]bool, *cryptopay.ApiError) {
+	if p[name] == "" {
+		return nil, nil
+	}
+	ids := make(map[int]bool)
+	for _, v := range strings.Split(p[name], ",") {
+		id, err := strconv.Atoi(strings.TrimSpace(v))
+		if err != nil {
+			return nil, invalid(name)
+		}
+		ids[id] = true
+	}
+	return ids, nil
+}
+
+// page returns bounds of requested page for list of n items.
+func (p params) page(n int) (int, int, *cryptopay.ApiError) {
+	offset, err := p.int("offset")
+	if err != nil || offset < 0 {
+		return 0, 0, invalid("offset")
+	}
+	count, err := p.int("count")
+	if err != nil || count < 0 || count > maxPageCount {
+		return 0, 0, invalid("count")
+	}
+	if count == 0 {
+		count = defaultPageCount
+	}
+	if offset > n {
+		offset = n
+	}
+	end := offset + count
+	if end > n {
+		end = n
+	}
+	return offset, end, nil
+}
+
+// time returns optional date in RFC3339 format.
+func (p params) time(name string) (time.Time, *cryptopay.ApiError) {
+	if p[name] == "" {
+		return time.Time{}, nil
+	}
+	v, err := time.Parse(time.RFC3339, p[name])
+	if err != nil {
+		return time.Time{}, invalid(name)
+	}
+	return v, nil
+}
+
+func (s *Server) getMe(params) (interface{}, *cryptopay.ApiError) {
+	return s.app, nil
+}
+
+func (s *Server) createInvoice(p params) (interface{}, *cryptopay.ApiError) {
+	inv := &invoice{Invoice: cryptopay.Invoice{
+		CurrencyType:  cryptopay.CurrencyType(p["currency_type"]),
+		Description:   p["description"],
+		HiddenMessage: p["hidden_message"],
+		Payload:       p["payload"],
+		PaidBtnName:   cryptopay.PaidButton(p["paid_btn_name"]),
+		PaidBtnUrl:    p["paid_btn_url"],
+	}}
+	var err *cryptopay.ApiError
+	switch inv.CurrencyType {
+	case "", cryptopay.CurrencyCrypto:
+		inv.CurrencyType = cryptopay.CurrencyCrypto
+		if inv.Asset, err = p.asset("asset"); err != nil {
+			return nil, err
+		}
+	case cryptopay.CurrencyFiat:
+		if p["fiat"] == "" {
+			return nil, invalid("fiat")
+		}
+		inv.Fiat = cryptopay.Fiat(p["fiat"])
+		if p["accepted_assets"] != "" {
+			for _, asset := range strings.Split(p["accepted_assets"], ",") {
+				inv.AcceptedAssets = append(inv.AcceptedAssets, cryptopay.Asset(asset))
+			}
+		}
+	default:
+		return nil, invalid("currency_type")
+	}
+	if inv.Amount, err = p.amount("amount"); err != nil {
+		return nil, err
+	}
+	if inv.AllowComments, err = p.bool("allow_comments", true); err != nil {
+		return nil, err
+	}
+	if inv.AllowAnonymous, err = p.bool("allow_anonymous", true); err != nil {
+		return nil, err
+	}
+	if inv.PaidBtnName != "" && inv.PaidBtnUrl == "" {
+		return nil, invalid("paid_btn_url")
+	}
+	expiresIn, err := p.int("expires_in")
+	if err != nil || expiresIn < 0 || expiresIn > maxExpiresIn {
+		return nil, cryptopay.ErrExpiresInInvalid
+	}
+
+	inv.Id = s.nextId()
+	inv.Status = cryptopay.StatusActive
+	inv.Hash = "IV" + strconv.Itoa(inv.Id)
+	inv.PayUrl = "https://t.me/" + s.app.PaymentBotUsername + "?start=" + inv.Hash
+	inv.CreatedAt = s.now()
+	if expiresIn > 0 {
+		inv.expiresAt = inv.CreatedAt.Add(time.Duration(expiresIn) * time.Second)
+		inv.ExpirationDate = inv.expiresAt.Format(time.RFC3339)
+	}
+	s.invoices = append(s.invoices, inv)
+	return inv.Invoice, nil
+}
+
+func (s *Server) deleteInvoice(p params) (interface{}, *cryptopay.ApiError) {
+	id, err := p.int("invoice_id")
+	if err != nil {
+		return nil, err
+	}
+	for i, inv := range s.invoices {
+		if inv.Id != id {
+			continue
+		}
+		s.expire(inv)
+		switch inv.Status {
+		case cryptopay.StatusPaid:
+			return nil, cryptopay.ErrInvoiceAlreadyPaid
+		case cryptopay.StatusExpired:
+			return nil, cryptopay.ErrInvoiceExpired
+		}
+		s.invoices = append(s.invoices[:i], s.invoices[i+1:]...)
+		return true, nil
+	}
+	return nil, cryptopay.ErrInvoiceNotFound
+}
+
+func (s *Server) transfer(p params) (interface{}, *cryptopay.ApiError) {
+	userId, err := p.int("user_id")
+	if err != nil || userId == 0 {
+		return nil, invalid("user_id")
+	}
+	asset, err := p.asset("asset")
+	if err != nil {
+		return nil, err
+	}
+	amount, err := p.amount("amount")
+	if err != nil {
+		return nil, err
+	}
+	spendId := p["spend_id"]
+	if spendId == "" {
+		return nil, invalid("spend_id")
+	}
+	// Repeated request with same spend_id returns original transfer without second debit.
+	if transfer, ok := s.spendIds[spendId]; ok {
+		return transfer, nil
+	}
+	if s.balances[asset].Cmp(amount) < 0 {
+		return nil, cryptopay.ErrInsufficientFunds
+	}
+	s.balances[asset] = s.balances[asset].Sub(amount)
+	transfer := &cryptopay.Transfer{
+		Id:          s.nextId(),
+		SpendId:     spendId,
+		UserId:      userId,
+		Asset:       asset,
+		Amount:      amount,
+		Status:      "completed",
+		CompletedAt: s.now(),
+		Comment:     p["comment"],
+	}
+	s.transfers = append(s.transfers, transfer)
+	s.spendIds[spendId] = transfer
+	return transfer, nil
+}
+
+func (s *Server) getInvoices(p params) (interface{}, *cryptopay.ApiError) {
+	ids, err := p.ids("invoice_ids")
+	if err != nil {
+		return nil, err
+	}
+	items := make([]cryptopay.Invoice, 0)
+	for _, inv := range s.invoices {
+		s.expire(inv)
+		if (ids != nil && !ids[inv.Id]) ||
+			(p["asset"] != "" && inv.Asset.String() != p["asset"]) ||
+			(p["fiat"] != "" && inv.Fiat.String() != p["fiat"]) ||
+			(p["status"] != "" && inv.Status.String() != p["status"]) {
+			continue
+		}
+		items = append(items, inv.Invoice)
+	}
+	start, end, err := p.page(len(items))
+	if err != nil {
+		return nil, err
+	}
+	return map[string]interface{}{"items": items[start:end]}, nil
+}
+
+func (s *Server) getTransfers(p params) (interface{}, *cryptopay.ApiError) {
+	ids, err := p.ids("transfer_ids")
+	if err != nil {
+		return nil, err
+	}
+	items := make([]*cryptopay.Transfer, 0)
+	for _, transfer := range s.transfers {
+		if (ids != nil && !ids[transfer.Id]) ||
+			(p["asset"] != "" && transfer.Asset.String() != p["asset"]) ||
+			(p["spend_id"] != "" && transfer.SpendId != p["spend_id"]) {
+			continue
+		}
+		items = append(items, transfer)
+	}
+	start, end, err := p.page(len(items))
+	if err != nil {
+		return nil, err
+	}
+	return map[string]interface{}{"items": items[start:end]}, nil
+}
+
+func (s *Server) getBalance(params) (interface{}, *cryptopay.ApiError) {
+	balance := make([]cryptopay.BalanceCurrency, 0, len(s.balances))
+	for asset, available := range s.balances {
+		balance = append(balance, cryptopay.BalanceCurrency{CurrencyCode: asset, Available: available})
+	}
+	sort.Slice(balance, func(i, j int) bool {
+		return balance[i].CurrencyCode < balance[j].CurrencyCode
+	})
+	return balance, nil
+}
+
+func (s *Server) getExchangeRates(params) (interface{}, *cryptopay.ApiError) {
+	return append([]cryptopay.ExchangeRate{}, s.rates...), nil
+}
+
+func (s *Server) getCurrencies(params) (interface{}, *cryptopay.ApiError) {
+	return currencies, nil
+}
+
+func (s *Server) createCheck(p params) (interface{}, *cryptopay.ApiError) {
+	asset, err := p.asset("asset")
+	if err != nil {
+		return nil, err
+	}
+	amount, err := p.amount("amount")
+	if err != nil {
+		return nil, err
+	}
+	if p["pin_to_user_id"] != "" && p["pin_to_username"] != "" {
+		return nil, invalid("pin_to_user_id")
+	}
+	if _, err := p.int("pin_to_user_id"); err != nil {
+		return nil, err
+	}
+	if s.balances[asset].Cmp(amount) < 0 {
+		return nil, cryptopay.ErrInsufficientFunds
+	}
+	s.balances[asset] = s.balances[asset].Sub(amount)
+	id := s.nextId()
+	check := &cryptopay.Check{
+		Id:          id,
+		Hash:        "CQ" + strconv.Itoa(id),
+		Asset:       asset,
+		Amount:      amount,
+		BotCheckUrl: "https://t.me/" + s.app.PaymentBotUsername + "?start=CQ" + strconv.Itoa(id),
+		Status:      cryptopay.CheckStatusActive,
+		CreatedAt:   s.now(),
+	}
+	s.checks = append(s.checks, check)
+	return check, nil
+}
+
+// deleteCheck deletes check and returns amount of active check to balance.
+func (s *Server) deleteCheck(p params) (interface{}, *cryptopay.ApiError) {
+	id, err := p.int("check_id")
+	if err != nil {
+		return nil, err
+	}
+	i, check := s.check(id)
+	if check == nil {
+		return nil, cryptopay.ErrCheckNotFound
+	}
+	if check.Status == cryptopay.CheckStatusActive {
+		s.balances[check.Asset] = s.balances[check.Asset].Add(check.Amount)
+	}
+	s.checks = append(s.checks[:i], s.checks[i+1:]...)
+	return true, nil
+}
+
+func (s *Server) getChecks(p params) (interface{}, *cryptopay.ApiError) {
+	ids, err := p.ids("check_ids")
+	if err != nil {
+		return nil, err
+	}
+	items := make([]*cryptopay.Check, 0)
+	for _, check := range s.checks {
+		if (ids != nil && !ids[check.Id]) ||
+			(p["asset"] != "" && check.Asset.String() != p["asset"]) ||
+			(p["status"] != "" && check.Status.String() != p["status"]) {
+			continue
+		}
+		items = append(items, check)
+	}
+	start, end, err := p.page(len(items))
+	if err != nil {
+		return nil, err
+	}
+	return map[string]interface{}{"items": items[start:end]}, nil
+}
+
+// getStats calculates statistics of invoices created in period.
+// Volume is sum of paid amounts valued in USD by exchange rates.
+func (s *Server) getStats(p params) (interface{}, *cryptopay.ApiError) {
+	endAt, err := p.time("end_at")
+	if err != nil {
+		return nil, err
+	}
+	if endAt.IsZero() {
+		endAt = s.now()
+	}
+	startAt, err := p.time("start_at")
+	if err != nil {
+		return nil, err
+	}
+	if startAt.IsZero() {
+		startAt = endAt.Add(-24 * time.Hour)
+	}
+	if startAt.After(endAt) {
+		return nil, invalid("start_at")
+	}
+	stats := cryptopay.AppStats{StartAt: startAt, EndAt: endAt}
+	users := make(map[int]bool)
+	for _, inv := range s.invoices {
+		if inv.CreatedAt.Before(startAt) || inv.CreatedAt.After(endAt) {
+			continue
+		}
+		stats.CreatedInvoiceCount++
+		s.expire(inv)
+		if inv.Status != cryptopay.StatusPaid {
+			continue
+		}
+		stats.PaidInvoiceCount++
+		users[inv.paidBy] = true
+		stats.Volume = stats.Volume.Add(inv.PaidAmount.Mul(s.rate(inv.PaidAsset, cryptopay.Asset(cryptopay.USD))))
+	}
+	stats.UniqueUsersCount = len(users)
+	if stats.CreatedInvoiceCount != 0 {
+		stats.Conversion = float64(stats.PaidInvoiceCount) / float64(stats.CreatedInvoiceCount)
+	}
+	return stats, nil
+}
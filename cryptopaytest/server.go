@@ -0,0 +1,348 @@
+// Package cryptopaytest provides in-memory fake of Crypto Pay API for integration tests.
+//
+// Server keeps state of one app: balances, invoices, transfers and checks.
+// Events that happen on the side of Crypto Pay (payment of invoice, activation of check)
+// are triggered by methods of Server. Payment of invoice sends signed webhook update
+// to registered URL, as real API does.
+//
+//	srv := cryptopaytest.NewServer("1:test_token")
+//	defer srv.Close()
+//	client := cryptopay.NewClient(srv.ClientSettings())
+package cryptopaytest
+
+import (
+	"bytes"
+	"encoding/json"
+	"errors"
+	"fmt"
+	"math/big"
+	"net/http"
+	"net/http/httptest"
+	"strconv"
+	"strings"
+	"sync"
+	"time"
+
+	"github.com/vitaliy-ukiru/go-cryptopay"
+)
+
+const headerSignatureName = "crypto-pay-api-signature"
+
+var (
+	// ErrorNotFound is returned if object with given ID doesn't exist.
+	ErrorNotFound = errors.New("crypto-pay/test: object not found")
+	// ErrorStatus is returned if object can't be changed because of its status.
+	// For example, paid invoice can't be expired.
+	ErrorStatus = errors.New("crypto-pay/test: wrong status of object")
+	// ErrorNoWebhook is returned by SendWebhook if webhook URL isn't set.
+	ErrorNoWebhook = errors.New("crypto-pay/test: webhook url is not set")
+)
+
+// Server is fake Crypto Pay API server. It's safe for concurrent use.
+type Server struct {
+	*httptest.Server
+
+	// WebhookClient is used for sending webhook updates. Default http.DefaultClient.
+	WebhookClient *http.Client
+
+	token string
+	app   cryptopay.AppInfo
+
+	mu         sync.Mutex
+	offset     time.Duration // shift of clock, see Advance
+	lastId     int
+	updateId   int
+	webhookUrl string
+	balances   map[cryptopay.Asset]cryptopay.Amount
+	rates      []cryptopay.ExchangeRate
+	invoices   []*invoice
+	transfers  []*cryptopay.Transfer
+	spendIds   map[string]*cryptopay.Transfer
+	checks     []*cryptopay.Check
+}
+
+// invoice is stored invoice with data that isn't returned by API.
+type invoice struct {
+	cryptopay.Invoice
+	expiresAt time.Time // zero if invoice doesn't expire
+	paidBy    int       // ID of user who paid the invoice
+}
+
+// NewServer starts and returns new Server for app with given token.
+// ID of the app is taken from token prefix before colon. Caller should call Close when finished.
+func NewServer(token string) *Server {
+	appId, _ := strconv.Atoi(strings.SplitN(token, ":", 2)[0])
+	s := &Server{
+		token: token,
+		app: cryptopay.AppInfo{
+			Id:                 appId,
+			Name:               "cryptopaytest",
+			PaymentBotUsername: "CryptoTestnetBot",
+		},
+		balances: make(map[cryptopay.Asset]cryptopay.Amount),
+		spendIds: make(map[string]*cryptopay.Transfer),
+	}
+	s.Server = httptest.NewServer(http.HandlerFunc(s.serveHTTP))
+	return s
+}
+
+// Token returns token of the app.
+func (s *Server) Token() string { return s.token }
+
+// ClientSettings returns settings for cryptopay.NewClient that point to this server.
+func (s *Server) ClientSettings() cryptopay.ClientSettings {
+	return cryptopay.ClientSettings{
+		Token:      s.token,
+		ApiHost:    s.URL,
+		HttpClient: s.Client(),
+	}
+}
+
+// Now returns current time of the server.
+func (s *Server) Now() time.Time {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+	return s.now()
+}
+
+// Advance moves clock of the server forward. Use it to expire invoices with ExpiresIn.
+func (s *Server) Advance(d time.Duration) {
+	s.mu.Lock()
+	s.offset += d
+	s.mu.Unlock()
+}
+
+// SetBalance sets available balance of the app in given asset.
+func (s *Server) SetBalance(asset cryptopay.Asset, amount cryptopay.Amount) {
+	s.mu.Lock()
+	s.balances[asset] = amount
+	s.mu.Unlock()
+}
+
+// Balance returns available balance of the app in given asset.
+func (s *Server) Balance(asset cryptopay.Asset) cryptopay.Amount {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+	return s.balances[asset]
+}
+
+// SetExchangeRate sets rate of source asset valued in target asset.
+// Rates are returned by getExchangeRates and used for payment of fiat invoices and statistics.
+func (s *Server) SetExchangeRate(source, target cryptopay.Asset, rate cryptopay.Amount) {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+	for i := range s.rates {
+		if s.rates[i].Source == source && s.rates[i].Target == target {
+			s.rates[i].Rate = rate
+			return
+		}
+	}
+	s.rates = append(s.rates, cryptopay.ExchangeRate{IsValid: true, Source: source, Target: target, Rate: rate})
+}
+
+// SetWebhook sets URL which receives webhook updates. Empty url disables webhooks.
+func (s *Server) SetWebhook(url string) {
+	s.mu.Lock()
+	s.webhookUrl = url
+	s.mu.Unlock()
+}
+
+// Invoice returns invoice by ID.
+func (s *Server) Invoice(id int) (cryptopay.Invoice, bool) {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+	inv := s.invoice(id)
+	if inv == nil {
+		return cryptopay.Invoice{}, false
+	}
+	return inv.Invoice, true
+}
+
+// PayInvoice marks active invoice as paid by user and credits balance of the app.
+// If webhook is set, update is sent before return and error of delivery is returned.
+//
+// Crypto invoice is paid in its asset. Fiat invoice is paid in first accepted asset (default USDT)
+// by rate set in SetExchangeRate (default 1).
+func (s *Server) PayInvoice(id, userId int) (cryptopay.Invoice, error) {
+	s.mu.Lock()
+	inv := s.invoice(id)
+	if inv == nil {
+		s.mu.Unlock()
+		return cryptopay.Invoice{}, ErrorNotFound
+	}
+	if inv.Status != cryptopay.StatusActive {
+		s.mu.Unlock()
+		return inv.Invoice, fmt.Errorf("%w: invoice %d is %s", ErrorStatus, id, inv.Status)
+	}
+	inv.Status = cryptopay.StatusPaid
+	inv.PaidAt = s.now()
+	inv.paidBy = userId
+	if inv.CurrencyType == cryptopay.CurrencyFiat {
+		inv.PaidAsset = cryptopay.USDT
+		if len(inv.AcceptedAssets) != 0 {
+			inv.PaidAsset = inv.AcceptedAssets[0]
+		}
+		rate := s.rate(inv.PaidAsset, cryptopay.Asset(inv.Fiat))
+		inv.PaidFiatRate = rate
+		inv.PaidAmount = divide(inv.Amount, rate)
+	} else {
+		inv.PaidAsset = inv.Asset
+		inv.PaidAmount = inv.Amount
+	}
+	s.balances[inv.PaidAsset] = s.balances[inv.PaidAsset].Add(inv.PaidAmount)
+	paid := inv.Invoice
+	hasWebhook := s.webhookUrl != ""
+	s.mu.Unlock()
+
+	if hasWebhook {
+		return paid, s.SendWebhook(id)
+	}
+	return paid, nil
+}
+
+// ExpireInvoice marks active invoice as expired.
+func (s *Server) ExpireInvoice(id int) error {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+	inv := s.invoice(id)
+	if inv == nil {
+		return ErrorNotFound
+	}
+	if inv.Status != cryptopay.StatusActive {
+		return fmt.Errorf("%w: invoice %d is %s", ErrorStatus, id, inv.Status)
+	}
+	inv.Status = cryptopay.StatusExpired
+	return nil
+}
+
+// ActivateCheck marks active check as activated.
+func (s *Server) ActivateCheck(id int) error {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+	_, check := s.check(id)
+	if check == nil {
+		return ErrorNotFound
+	}
+	if check.Status != cryptopay.CheckStatusActive {
+		return fmt.Errorf("%w: check %d is %s", ErrorStatus, id, check.Status)
+	}
+	check.Status = cryptopay.CheckStatusActivated
+	check.ActivatedAt = s.now()
+	return nil
+}
+
+// SendWebhook sends update about paid invoice to webhook URL.
+// It can be used for simulating repeated delivery of update.
+func (s *Server) SendWebhook(invoiceId int) error {
+	url, update, err := s.newUpdate(invoiceId)
+	if err != nil {
+		return err
+	}
+
+	body, err := json.Marshal(update)
+	if err != nil {
+		return err
+	}
+	req, err := http.NewRequest(http.MethodPost, url, bytes.NewReader(body))
+	if err != nil {
+		return err
+	}
+	req.Header.Set("Content-Type", "application/json")
+	req.Header.Set(headerSignatureName, cryptopay.SignWebhookBody(s.token, body))
+	client := s.WebhookClient
+	if client == nil {
+		client = http.DefaultClient
+	}
+	resp, err := client.Do(req)
+	if err != nil {
+		return err
+	}
+	resp.Body.Close()
+	if resp.StatusCode != http.StatusOK {
+		return fmt.Errorf("crypto-pay/test: webhook responded with status %d", resp.StatusCode)
+	}
+	return nil
+}
+
+// newUpdate returns webhook URL and update about paid invoice.
+func (s *Server) newUpdate(invoiceId int) (string, *cryptopay.WebhookUpdate, error) {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+	if s.webhookUrl == "" {
+		return "", nil, ErrorNoWebhook
+	}
+	inv := s.invoice(invoiceId)
+	if inv == nil {
+		return "", nil, ErrorNotFound
+	}
+	if inv.Status != cryptopay.StatusPaid {
+		return "", nil, fmt.Errorf("%w: invoice %d is %s", ErrorStatus, invoiceId, inv.Status)
+	}
+	s.updateId++
+	return s.webhookUrl, &cryptopay.WebhookUpdate{
+		Id:          s.updateId,
+		UpdateType:  cryptopay.UpdateInvoicePaid,
+		RequestDate: s.now(),
+		Payload:     inv.Invoice,
+	}, nil
+}
+
+// now returns current time of the server. Must be called with locked mu.
+func (s *Server) now() time.Time {
+	return time.Now().Add(s.offset).UTC()
+}
+
+// nextId returns new ID for invoice, transfer or check. Must be called with locked mu.
+func (s *Server) nextId() int {
+	s.lastId++
+	return s.lastId
+}
+
+// invoice returns invoice by ID with actual status. Must be called with locked mu.
+func (s *Server) invoice(id int) *invoice {
+	for _, inv := range s.invoices {
+		if inv.Id == id {
+			s.expire(inv)
+			return inv
+		}
+	}
+	return nil
+}
+
+// expire marks invoice as expired if its time is over. Must be called with locked mu.
+func (s *Server) expire(inv *invoice) {
+	if inv.Status == cryptopay.StatusActive && !inv.expiresAt.IsZero() && !s.now().Before(inv.expiresAt) {
+		inv.Status = cryptopay.StatusExpired
+	}
+}
+
+// check returns index and check by ID. Must be called with locked mu.
+func (s *Server) check(id int) (int, *cryptopay.Check) {
+	for i, check := range s.checks {
+		if check.Id == id {
+			return i, check
+		}
+	}
+	return -1, nil
+}
+
+// rate returns rate of source asset valued in target. Default 1. Must be called with locked mu.
+func (s *Server) rate(source, target cryptopay.Asset) cryptopay.Amount {
+	for _, rate := range s.rates {
+		if rate.Source == source && rate.Target == target && rate.Rate.Sign() > 0 {
+			return rate.Rate
+		}
+	}
+	return cryptopay.NewAmount(1, 0)
+}
+
+// divide returns a / b rounded to 8 decimal places.
+func divide(a, b cryptopay.Amount) cryptopay.Amount {
+	if b.Equal(cryptopay.NewAmount(1, 0)) {
+		return a
+	}
+	x, _ := new(big.Rat).SetString(a.String())
+	y, _ := new(big.Rat).SetString(b.String())
+	q, _ := cryptopay.ParseAmount(x.Quo(x, y).FloatString(8))
+	return q
+}
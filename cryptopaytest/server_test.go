@@ -0,0 +1,280 @@
+package cryptopaytest
+
+import (
+	"errors"
+	"net/http"
+	"net/http/httptest"
+	"strconv"
+	"testing"
+	"time"
+
+	"github.com/vitaliy-ukiru/go-cryptopay"
+)
+
+func newClient(t *testing.T) (*Server, *cryptopay.Client) {
+	srv := NewServer("42:test_token")
+	t.Cleanup(srv.Close)
+	return srv, cryptopay.NewClient(srv.ClientSettings())
+}
+
+func TestServer_GetMe(t *testing.T) {
+	_, c := newClient(t)
+	app, err := c.GetMe()
+	if err != nil {
+		t.Fatal(err)
+	}
+	if app.Id != 42 {
+		t.Errorf("app id(%d) != 42", app.Id)
+	}
+
+	srv := NewServer("1:other")
+	defer srv.Close()
+	settings := srv.ClientSettings()
+	settings.Token = "1:wrong"
+	if _, err := cryptopay.NewClient(settings).GetMe(); !errors.Is(err, cryptopay.ErrUnauthorized) {
+		t.Errorf("err(%v) != ErrUnauthorized", err)
+	}
+}
+
+func TestServer_Invoice(t *testing.T) {
+	srv, c := newClient(t)
+	invoice, err := c.CreateInvoice(cryptopay.TON, cryptopay.MustParseAmount("2.5"), cryptopay.CreateInvoiceOptions{})
+	if err != nil {
+		t.Fatal(err)
+	}
+	if invoice.Status != cryptopay.StatusActive || !invoice.AllowComments || invoice.PayUrl == "" {
+		t.Errorf("unexpected invoice %#v", invoice)
+	}
+
+	paid, err := srv.PayInvoice(invoice.Id, 7)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if paid.Status != cryptopay.StatusPaid || paid.PaidAt.IsZero() {
+		t.Errorf("unexpected paid invoice %#v", paid)
+	}
+	if balance := srv.Balance(cryptopay.TON); balance.String() != "2.5" {
+		t.Errorf("balance(%s) != 2.5", balance)
+	}
+	if _, err := srv.PayInvoice(invoice.Id, 7); !errors.Is(err, ErrorStatus) {
+		t.Errorf("paid twice, err(%v)", err)
+	}
+	if err := c.DeleteInvoice(invoice.Id); !errors.Is(err, cryptopay.ErrInvoiceAlreadyPaid) {
+		t.Errorf("err(%v) != ErrInvoiceAlreadyPaid", err)
+	}
+
+	invoices, err := c.GetInvoices(&cryptopay.GetInvoicesOptions{Status: cryptopay.StatusPaid})
+	if err != nil {
+		t.Fatal(err)
+	}
+	if len(invoices) != 1 || invoices[0].Id != invoice.Id {
+		t.Errorf("unexpected invoices %#v", invoices)
+	}
+}
+
+func TestServer_InvoiceExpiration(t *testing.T) {
+	srv, c := newClient(t)
+	invoice, err := c.CreateInvoice(cryptopay.BTC, cryptopay.NewAmount(1, 0), cryptopay.CreateInvoiceOptions{ExpiresIn: 60})
+	if err != nil {
+		t.Fatal(err)
+	}
+	srv.Advance(time.Minute)
+	if got, _ := srv.Invoice(invoice.Id); got.Status != cryptopay.StatusExpired {
+		t.Errorf("status(%s) != expired", got.Status)
+	}
+	if _, err := srv.PayInvoice(invoice.Id, 1); !errors.Is(err, ErrorStatus) {
+		t.Errorf("expired invoice paid, err(%v)", err)
+	}
+
+	active, _ := c.CreateInvoice(cryptopay.BTC, cryptopay.NewAmount(1, 0), cryptopay.CreateInvoiceOptions{})
+	if err := c.DeleteInvoice(active.Id); err != nil {
+		t.Error(err)
+	}
+	if _, ok := srv.Invoice(active.Id); ok {
+		t.Error("invoice not deleted")
+	}
+}
+
+func TestServer_FiatInvoice(t *testing.T) {
+	srv, c := newClient(t)
+	srv.SetExchangeRate(cryptopay.TON, cryptopay.Asset(cryptopay.USD), cryptopay.NewAmount(2, 0))
+	invoice, err := c.CreateInvoice("", cryptopay.NewAmount(10, 0), cryptopay.CreateInvoiceOptions{
+		CurrencyType:   cryptopay.CurrencyFiat,
+		Fiat:           cryptopay.USD,
+		AcceptedAssets: []cryptopay.Asset{cryptopay.TON},
+	})
+	if err != nil {
+		t.Fatal(err)
+	}
+	paid, err := srv.PayInvoice(invoice.Id, 1)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if paid.PaidAsset != cryptopay.TON || !paid.PaidAmount.Equal(cryptopay.NewAmount(5, 0)) {
+		t.Errorf("paid %s %s, expected 5 TON", paid.PaidAmount, paid.PaidAsset)
+	}
+
+	stats, err := c.GetStats(nil)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if stats.PaidInvoiceCount != 1 || !stats.Volume.Equal(cryptopay.NewAmount(10, 0)) || stats.Conversion != 1 {
+		t.Errorf("unexpected stats %#v", stats)
+	}
+}
+
+func TestServer_InvoiceIds(t *testing.T) {
+	_, c := newClient(t)
+	var ids []string
+	for i := 0; i < 3; i++ {
+		invoice, err := c.CreateInvoice(cryptopay.TON, cryptopay.NewAmount(1, 0), cryptopay.CreateInvoiceOptions{})
+		if err != nil {
+			t.Fatal(err)
+		}
+		ids = append(ids, strconv.Itoa(invoice.Id))
+	}
+	invoices, err := c.GetInvoices(&cryptopay.GetInvoicesOptions{InvoiceIds: ids[1:]})
+	if err != nil {
+		t.Fatal(err)
+	}
+	if len(invoices) != 2 || strconv.Itoa(invoices[0].Id) != ids[1] {
+		t.Errorf("unexpected invoices %#v", invoices)
+	}
+}
+
+func TestDivide(t *testing.T) {
+	var cases = []struct{ a, b, expected string }{
+		{"10", "3", "3.33333333"},
+		{"0.3", "0.1", "3"},
+		{"12345678901.23456789", "1.5", "8230452600.82304526"},
+	}
+	for _, tc := range cases {
+		got := divide(cryptopay.MustParseAmount(tc.a), cryptopay.MustParseAmount(tc.b))
+		if !got.Equal(cryptopay.MustParseAmount(tc.expected)) {
+			t.Errorf("%s / %s = %s, expected %s", tc.a, tc.b, got, tc.expected)
+		}
+	}
+}
+
+func TestServer_Transfer(t *testing.T) {
+	srv, c := newClient(t)
+	srv.SetBalance(cryptopay.USDT, cryptopay.NewAmount(10, 0))
+
+	transfer, err := c.DoTransfer(1, cryptopay.USDT, cryptopay.NewAmount(3, 0), "order-1", cryptopay.DoTransferOptions{})
+	if err != nil {
+		t.Fatal(err)
+	}
+	again, err := c.DoTransfer(1, cryptopay.USDT, cryptopay.NewAmount(3, 0), "order-1", cryptopay.DoTransferOptions{})
+	if err != nil {
+		t.Fatal(err)
+	}
+	if again.Id != transfer.Id {
+		t.Errorf("transfer with same spend_id created twice")
+	}
+	if balance := srv.Balance(cryptopay.USDT); balance.String() != "7" {
+		t.Errorf("balance(%s) != 7", balance)
+	}
+
+	_, err = c.DoTransfer(1, cryptopay.USDT, cryptopay.NewAmount(8, 0), "order-2", cryptopay.DoTransferOptions{})
+	if !errors.Is(err, cryptopay.ErrInsufficientFunds) {
+		t.Errorf("err(%v) != ErrInsufficientFunds", err)
+	}
+
+	transfers, err := c.GetTransfers(&cryptopay.GetTransfersOptions{SpendId: "order-1"})
+	if err != nil {
+		t.Fatal(err)
+	}
+	if len(transfers) != 1 {
+		t.Errorf("count(%d) != 1", len(transfers))
+	}
+}
+
+func TestServer_Check(t *testing.T) {
+	srv, c := newClient(t)
+	srv.SetBalance(cryptopay.TON, cryptopay.NewAmount(5, 0))
+	check, err := c.CreateCheck(cryptopay.TON, cryptopay.NewAmount(2, 0), cryptopay.CreateCheckOptions{})
+	if err != nil {
+		t.Fatal(err)
+	}
+	if balance := srv.Balance(cryptopay.TON); balance.String() != "3" {
+		t.Errorf("balance(%s) != 3", balance)
+	}
+	if err := c.DeleteCheck(check.Id); err != nil {
+		t.Fatal(err)
+	}
+	if balance := srv.Balance(cryptopay.TON); balance.String() != "5" {
+		t.Errorf("balance(%s) != 5, check not refunded", balance)
+	}
+
+	check, _ = c.CreateCheck(cryptopay.TON, cryptopay.NewAmount(1, 0), cryptopay.CreateCheckOptions{})
+	if err := srv.ActivateCheck(check.Id); err != nil {
+		t.Fatal(err)
+	}
+	checks, err := c.GetChecks(&cryptopay.GetChecksOptions{Status: cryptopay.CheckStatusActivated})
+	if err != nil {
+		t.Fatal(err)
+	}
+	if len(checks) != 1 || checks[0].ActivatedAt.IsZero() {
+		t.Errorf("unexpected checks %#v", checks)
+	}
+}
+
+func TestServer_FormatQuery(t *testing.T) {
+	srv := NewServer("1:test_token")
+	defer srv.Close()
+	settings := srv.ClientSettings()
+	settings.RequestFormat = cryptopay.FormatQuery
+	c := cryptopay.NewClient(settings)
+	invoice, err := c.CreateInvoice(cryptopay.ETH, cryptopay.MustParseAmount("0.1"), cryptopay.CreateInvoiceOptions{
+		AllowComments: cryptopay.Bool(false),
+	})
+	if err != nil {
+		t.Fatal(err)
+	}
+	if invoice.AllowComments || invoice.Amount.String() != "0.1" {
+		t.Errorf("unexpected invoice %#v", invoice)
+	}
+}
+
+func TestServer_Webhook(t *testing.T) {
+	srv, c := newClient(t)
+	updates := make(chan *cryptopay.WebhookUpdate, 1)
+	webhook := cryptopay.NewWebhook(srv.Token(), nil, func(_ *http.Request, err error) {
+		t.Error(err)
+	})
+	webhook.Bind(cryptopay.UpdateInvoicePaid, func(update *cryptopay.WebhookUpdate) {
+		updates <- update
+	})
+	endpoint := httptest.NewServer(webhook)
+	defer endpoint.Close()
+
+	invoice, _ := c.CreateInvoice(cryptopay.TON, cryptopay.NewAmount(1, 0), cryptopay.CreateInvoiceOptions{})
+	if _, err := srv.PayInvoice(invoice.Id, 1); err != nil {
+		t.Fatal(err)
+	}
+	if err := srv.SendWebhook(invoice.Id); !errors.Is(err, ErrorNoWebhook) {
+		t.Errorf("err(%v) != ErrorNoWebhook", err)
+	}
+
+	srv.SetWebhook(endpoint.URL)
+	if err := srv.SendWebhook(invoice.Id); err != nil {
+		t.Fatal(err)
+	}
+	select {
+	case update := <-updates:
+		if update.Payload.Id != invoice.Id || update.Payload.Status != cryptopay.StatusPaid {
+			t.Errorf("unexpected update %#v", update)
+		}
+	case <-time.After(time.Second):
+		t.Fatal("update not received")
+	}
+
+	wrong := NewServer("1:wrong_token")
+	defer wrong.Close()
+	wrong.SetWebhook(endpoint.URL)
+	invoice, _ = cryptopay.NewClient(wrong.ClientSettings()).CreateInvoice(cryptopay.TON, cryptopay.NewAmount(1, 0), cryptopay.CreateInvoiceOptions{})
+	webhook.OnError = nil
+	if _, err := wrong.PayInvoice(invoice.Id, 1); err == nil {
+		t.Error("update with wrong signature accepted")
+	}
+}
@@ -0,0 +1,180 @@
+package cryptopay
+
+import (
+	"bufio"
+	"fmt"
+	"io"
+	"os"
+	"strconv"
+	"strings"
+	"sync"
+	"time"
+)
+
+// DedupStore remembers keys of processed webhook updates. Implementations must be safe for concurrent use.
+//
+// Webhook marks key of update as seen before dispatch and forgets it if handlers failed,
+// so redelivered update is processed again.
+type DedupStore interface {
+	// MarkSeen marks key as seen. Returns false if key is already seen.
+	MarkSeen(key string) (bool, error)
+	// Forget removes key, so update with it will be processed again.
+	Forget(key string) error
+}
+
+// UpdateKey returns key of logical event for deduplication: update type, invoice ID and status of invoice.
+// Unlike WebhookUpdate.Id, it's same for all deliveries of event.
+func UpdateKey(update *WebhookUpdate) string {
+	return fmt.Sprintf("%s:%d:%s", update.UpdateType, update.Payload.Id, update.Payload.Status)
+}
+
+// MemoryDedupStore is DedupStore that keeps keys in memory for TTL.
+type MemoryDedupStore struct {
+	ttl time.Duration
+
+	mu      sync.Mutex
+	keys    map[string]time.Time // key to time of expiration, zero time means never
+	sweepAt time.Time
+}
+
+// NewMemoryDedupStore returns new MemoryDedupStore. Keys expire after ttl, zero ttl means keys never expire.
+func NewMemoryDedupStore(ttl time.Duration) *MemoryDedupStore {
+	return &MemoryDedupStore{ttl: ttl, keys: make(map[string]time.Time)}
+}
+
+// MarkSeen implements DedupStore.
+func (s *MemoryDedupStore) MarkSeen(key string) (bool, error) {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+	now := time.Now()
+	s.sweep(now)
+	if expiresAt, ok := s.keys[key]; ok && (expiresAt.IsZero() || now.Before(expiresAt)) {
+		return false, nil
+	}
+	var expiresAt time.Time
+	if s.ttl > 0 {
+		expiresAt = now.Add(s.ttl)
+	}
+	s.keys[key] = expiresAt
+	return true, nil
+}
+
+// Forget implements DedupStore.
+func (s *MemoryDedupStore) Forget(key string) error {
+	s.mu.Lock()
+	delete(s.keys, key)
+	s.mu.Unlock()
+	return nil
+}
+
+// sweep deletes expired keys not more often than once per ttl. Must be called with locked mu.
+func (s *MemoryDedupStore) sweep(now time.Time) {
+	if s.ttl <= 0 || now.Before(s.sweepAt) {
+		return
+	}
+	for key, expiresAt := range s.keys {
+		if !now.Before(expiresAt) {
+			delete(s.keys, key)
+		}
+	}
+	s.sweepAt = now.Add(s.ttl)
+}
+
+// FileDedupStore is DedupStore that keeps keys in file, so they survive restart of the program.
+// Keys never expire. File is append-only log of marks and forgets, it's read on open.
+type FileDedupStore struct {
+	mu   sync.Mutex
+	file *os.File
+	keys map[string]bool
+}
+
+// NewFileDedupStore opens or creates file with keys. Caller should call Close when finished.
+//
+// Unterminated last line is left by crash during write, so it's truncated. Invalid complete line is error.
+func NewFileDedupStore(path string) (*FileDedupStore, error) {
+	file, err := os.OpenFile(path, os.O_RDWR|os.O_CREATE|os.O_APPEND, 0o600)
+	if err != nil {
+		return nil, err
+	}
+	keys := make(map[string]bool)
+	reader := bufio.NewReader(file)
+	var size int64 // size of complete lines
+	for {
+		line, err := reader.ReadString('\n')
+		if err == io.EOF {
+			if line != "" {
+				err = file.Truncate(size)
+			} else {
+				err = nil
+			}
+			if err != nil {
+				file.Close()
+				return nil, err
+			}
+			break
+		}
+		if err != nil {
+			file.Close()
+			return nil, err
+		}
+		size += int64(len(line))
+		line = strings.TrimSuffix(line, "\n")
+		if len(line) < 2 {
+			continue
+		}
+		key, err := strconv.Unquote(line[1:])
+		if err != nil {
+			file.Close()
+			return nil, fmt.Errorf("crypto-pay/webhook: corrupted dedup file %s: %w", path, err)
+		}
+		if line[0] == '+' {
+			keys[key] = true
+		} else {
+			delete(keys, key)
+		}
+	}
+	return &FileDedupStore{file: file, keys: keys}, nil
+}
+
+// MarkSeen implements DedupStore. Key is synced to disk before return.
+func (s *FileDedupStore) MarkSeen(key string) (bool, error) {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+	if s.keys[key] {
+		return false, nil
+	}
+	if err := s.write('+', key); err != nil {
+		return false, err
+	}
+	s.keys[key] = true
+	return true, nil
+}
+
+// Forget implements DedupStore.
+func (s *FileDedupStore) Forget(key string) error {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+	if !s.keys[key] {
+		return nil
+	}
+	if err := s.write('-', key); err != nil {
+		return err
+	}
+	delete(s.keys, key)
+	return nil
+}
+
+// Close closes file of the store.
+func (s *FileDedupStore) Close() error {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+	return s.file.Close()
+}
+
+// write appends record to file. Must be called with locked mu.
+func (s *FileDedupStore) write(op byte, key string) error {
+	if _, err := s.file.WriteString(string(op) + strconv.Quote(key) + "\n"); err != nil {
+		return err
+	}
+	return s.file.Sync()
+}
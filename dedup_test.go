@@ -0,0 +1,218 @@
+package cryptopay
+
+import (
+	"context"
+	"errors"
+	"fmt"
+	"net/http"
+	"os"
+	"path/filepath"
+	"sync"
+	"sync/atomic"
+	"testing"
+	"time"
+)
+
+func TestMemoryDedupStore(t *testing.T) {
+	s := NewMemoryDedupStore(20 * time.Millisecond)
+	if first, _ := s.MarkSeen("a"); !first {
+		t.Error("new key is seen")
+	}
+	if first, _ := s.MarkSeen("a"); first {
+		t.Error("key is not seen")
+	}
+	s.Forget("a")
+	if first, _ := s.MarkSeen("a"); !first {
+		t.Error("forgotten key is seen")
+	}
+	time.Sleep(30 * time.Millisecond)
+	if first, _ := s.MarkSeen("a"); !first {
+		t.Error("expired key is seen")
+	}
+	if len(s.keys) != 1 {
+		t.Errorf("expired keys not swept, count(%d)", len(s.keys))
+	}
+}
+
+func TestFileDedupStore(t *testing.T) {
+	path := filepath.Join(t.TempDir(), "dedup.log")
+	s, err := NewFileDedupStore(path)
+	if err != nil {
+		t.Fatal(err)
+	}
+	for _, key := range []string{"invoice_paid:1:paid", "invoice_paid:2:paid", "with\nnewline"} {
+		if first, err := s.MarkSeen(key); !first || err != nil {
+			t.Fatalf("MarkSeen(%q) = %v, %v", key, first, err)
+		}
+	}
+	if err := s.Forget("invoice_paid:2:paid"); err != nil {
+		t.Fatal(err)
+	}
+	s.Close()
+
+	s, err = NewFileDedupStore(path)
+	if err != nil {
+		t.Fatal(err)
+	}
+	defer s.Close()
+	var cases = []struct {
+		key  string
+		seen bool
+	}{
+		{key: "invoice_paid:1:paid", seen: true},
+		{key: "with\nnewline", seen: true},
+		{key: "invoice_paid:2:paid", seen: false},
+	}
+	for _, tc := range cases {
+		if first, _ := s.MarkSeen(tc.key); first == tc.seen {
+			t.Errorf("key %q: seen(%v) after reopen, expected %v", tc.key, !first, tc.seen)
+		}
+	}
+}
+
+func TestFileDedupStore_PartialLine(t *testing.T) {
+	path := filepath.Join(t.TempDir(), "dedup.log")
+	s, err := NewFileDedupStore(path)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if _, err := s.MarkSeen("invoice_paid:1:paid"); err != nil {
+		t.Fatal(err)
+	}
+	s.Close()
+
+	f, err := os.OpenFile(path, os.O_WRONLY|os.O_APPEND, 0o600)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if _, err := f.WriteString(`+"inv`); err != nil {
+		t.Fatal(err)
+	}
+	f.Close()
+
+	s, err = NewFileDedupStore(path)
+	if err != nil {
+		t.Fatalf("reopen with partial line: %v", err)
+	}
+	if first, _ := s.MarkSeen("invoice_paid:1:paid"); first {
+		t.Error("complete key is lost after reopen")
+	}
+	if first, _ := s.MarkSeen("invoice_paid:2:paid"); !first {
+		t.Error("new key is seen")
+	}
+	s.Close()
+
+	s, err = NewFileDedupStore(path)
+	if err != nil {
+		t.Fatalf("reopen after write: %v", err)
+	}
+	defer s.Close()
+	if first, _ := s.MarkSeen("invoice_paid:2:paid"); first {
+		t.Error("key written after partial line is lost")
+	}
+
+	if err := os.WriteFile(path, []byte("+\"inv\n"), 0o600); err != nil {
+		t.Fatal(err)
+	}
+	if _, err := NewFileDedupStore(path); err == nil {
+		t.Error("corrupted complete line is accepted")
+	}
+}
+
+func TestWebhook_Dedup(t *testing.T) {
+	var calls int32
+	var fail int32 = 1
+	w := getWebhook(nil, nil)
+	w.Dedup = NewMemoryDedupStore(0)
+	w.Handle(UpdateInvoicePaid, func(ctx context.Context, update *WebhookUpdate) error {
+		atomic.AddInt32(&calls, 1)
+		if atomic.LoadInt32(&fail) == 1 {
+			return errors.New("failed")
+		}
+		return nil
+	})
+	update := &WebhookUpdate{Id: 1, UpdateType: UpdateInvoicePaid, Payload: Invoice{Id: 5, Status: StatusPaid}}
+
+	if code := serveUpdate(w, update); code != http.StatusInternalServerError {
+		t.Fatalf("status(%d) != 500", code)
+	}
+	atomic.StoreInt32(&fail, 0)
+	for i := 0; i < 3; i++ {
+		// Update ID differs between deliveries.
+		update.Id++
+		if code := serveUpdate(w, update); code != http.StatusOK {
+			t.Fatalf("status(%d) != 200", code)
+		}
+	}
+	if calls != 2 {
+		t.Errorf("handler called %d times, expected 2 (failed and redelivered)", calls)
+	}
+}
+
+func TestWebhook_DedupTimeout(t *testing.T) {
+	for _, failed := range []bool{false, true} {
+		t.Run(fmt.Sprint("failed ", failed), func(t *testing.T) {
+			var calls int32
+			var reported []error
+			var mu sync.Mutex
+			w := getWebhook(nil, func(_ *http.Request, err error) {
+				mu.Lock()
+				reported = append(reported, err)
+				mu.Unlock()
+			})
+			w.Dedup = NewMemoryDedupStore(0)
+			w.HandlerTimeout = 10 * time.Millisecond
+			release := make(chan struct{})
+			w.Handle(UpdateInvoicePaid, func(ctx context.Context, update *WebhookUpdate) error {
+				if atomic.AddInt32(&calls, 1) == 1 {
+					<-release
+					if failed {
+						return errors.New("failed")
+					}
+				}
+				return nil
+			})
+			update := &WebhookUpdate{Id: 1, UpdateType: UpdateInvoicePaid, Payload: Invoice{Id: 5, Status: StatusPaid}}
+
+			if code := serveUpdate(w, update); code != http.StatusServiceUnavailable {
+				t.Fatalf("status(%d) != 503 on timeout", code)
+			}
+			// Redelivery while first delivery is still running.
+			if code := serveUpdate(w, update); code != http.StatusServiceUnavailable {
+				t.Fatalf("status(%d) != 503 for update in progress", code)
+			}
+			mu.Lock()
+			if n := len(reported); n != 2 || !errors.Is(reported[1], ErrorUpdateInProgress) {
+				t.Errorf("reported %v, expected ErrorUpdateInProgress", reported)
+			}
+			mu.Unlock()
+
+			close(release)
+			waitIdle(t, w)
+			if code := serveUpdate(w, update); code != http.StatusOK {
+				t.Fatalf("status(%d) != 200 after first delivery finished", code)
+			}
+			expected := int32(1)
+			if failed {
+				// Failed delivery forgets the key, so redelivery is processed.
+				expected = 2
+			}
+			if n := atomic.LoadInt32(&calls); n != expected {
+				t.Errorf("handler called %d times, expected %d", n, expected)
+			}
+		})
+	}
+}
+
+// waitIdle waits until webhook finishes processing of all updates.
+func waitIdle(t *testing.T, w *Webhook) {
+	for deadline := time.Now().Add(time.Second); time.Now().Before(deadline); time.Sleep(time.Millisecond) {
+		w.stateMu.Lock()
+		inflight := w.inflight
+		w.stateMu.Unlock()
+		if inflight == 0 {
+			return
+		}
+	}
+	t.Fatal("webhook is not idle")
+}
@@ -0,0 +1,102 @@
+package cryptopay
+
+import (
+	"errors"
+	"sync"
+)
+
+// ErrorQueueFull is passed to Webhook.OnError if Dispatcher can't accept update because queue of worker is full.
+// Webhook responds with 503 status code, so API redelivers the update later.
+var ErrorQueueFull = errors.New("crypto-pay/webhook: dispatch queue is full")
+
+// Dispatcher runs asynchronous handlers of updates by fixed pool of workers instead of goroutine per handler.
+//
+// Updates are sharded between workers by invoice ID: every worker has own queue and handles updates one by one,
+// so updates of the same invoice are handled in order of arrival and never concurrently.
+// Handlers of one update are called sequentially in order of binding.
+type Dispatcher struct {
+	// mu protects closed and sending to queues.
+	mu     sync.RWMutex
+	closed bool
+	queues []chan dispatchJob
+	wg     sync.WaitGroup
+}
+
+// dispatchJob is update with its asynchronous handlers.
+type dispatchJob struct {
+	update   *WebhookUpdate
+	handlers []Handler
+	done     func() // called after handlers, may be nil
+}
+
+// NewDispatcher starts workers and returns new Dispatcher. Every worker has queue of queueSize updates,
+// zero queueSize means update is accepted only by idle worker. Workers less than 1 means 1 worker.
+// Caller should call Close when finished.
+func NewDispatcher(workers, queueSize int) *Dispatcher {
+	if workers < 1 {
+		workers = 1
+	}
+	if queueSize < 0 {
+		queueSize = 0
+	}
+	d := &Dispatcher{queues: make([]chan dispatchJob, workers)}
+	for i := range d.queues {
+		d.queues[i] = make(chan dispatchJob, queueSize)
+		d.wg.Add(1)
+		go d.work(d.queues[i])
+	}
+	return d
+}
+
+// Dispatch puts update to queue of its worker without blocking.
+// Returns false if queue is full or dispatcher is closed.
+// Panics of handlers aren't recovered, Webhook wraps its handlers before dispatch.
+func (d *Dispatcher) Dispatch(update *WebhookUpdate, handlers []Handler) bool {
+	return d.dispatch(dispatchJob{update: update, handlers: handlers})
+}
+
+// dispatch puts job to queue of its worker without blocking.
+func (d *Dispatcher) dispatch(job dispatchJob) bool {
+	d.mu.RLock()
+	defer d.mu.RUnlock()
+	if d.closed {
+		return false
+	}
+	select {
+	case d.queue(job.update) <- job:
+		return true
+	default:
+		return false
+	}
+}
+
+// Close stops accepting of updates and waits until workers handle queued updates.
+func (d *Dispatcher) Close() {
+	d.mu.Lock()
+	if !d.closed {
+		d.closed = true
+		for _, queue := range d.queues {
+			close(queue)
+		}
+	}
+	d.mu.Unlock()
+	d.wg.Wait()
+}
+
+// queue returns queue of worker for update.
+func (d *Dispatcher) queue(update *WebhookUpdate) chan<- dispatchJob {
+	return d.queues[uint(update.Payload.Id)%uint(len(d.queues))]
+}
+
+// work handles updates from queue until it's closed.
+func (d *Dispatcher) work(queue <-chan dispatchJob) {
+	defer d.wg.Done()
+	for job := range queue {
+		for _, handler := range job.handlers {
+			handler(job.update)
+		}
+		if job.done != nil {
+			job.done()
+		}
+	}
+}
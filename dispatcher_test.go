@@ -0,0 +1,147 @@
+package cryptopay
+
+import (
+	"context"
+	"errors"
+	"net/http"
+	"sync"
+	"testing"
+	"time"
+)
+
+func TestDispatcher_Order(t *testing.T) {
+	d := NewDispatcher(4, 10)
+	var (
+		mu      sync.Mutex
+		running = make(map[int]bool)
+		order   = make(map[int][]int)
+	)
+	handler := func(update *WebhookUpdate) {
+		mu.Lock()
+		if running[update.Payload.Id] {
+			t.Errorf("updates of invoice %d handled concurrently", update.Payload.Id)
+		}
+		running[update.Payload.Id] = true
+		mu.Unlock()
+		time.Sleep(time.Millisecond)
+		mu.Lock()
+		running[update.Payload.Id] = false
+		order[update.Payload.Id] = append(order[update.Payload.Id], update.Id)
+		mu.Unlock()
+	}
+	for i := 0; i < 5; i++ {
+		for invoiceId := 1; invoiceId <= 3; invoiceId++ {
+			update := &WebhookUpdate{Id: i, Payload: Invoice{Id: invoiceId}}
+			if !d.Dispatch(update, []Handler{handler}) {
+				t.Fatalf("update %d of invoice %d not accepted", i, invoiceId)
+			}
+		}
+	}
+	d.Close()
+	for invoiceId, ids := range order {
+		for i, id := range ids {
+			if id != i {
+				t.Errorf("invoice %d: order %v", invoiceId, ids)
+				break
+			}
+		}
+	}
+	if len(order) != 3 {
+		t.Errorf("handled invoices(%d) != 3", len(order))
+	}
+	if d.Dispatch(&WebhookUpdate{}, nil) {
+		t.Error("closed dispatcher accepted update")
+	}
+}
+
+func TestWebhook_Dispatcher(t *testing.T) {
+	var (
+		mu       sync.Mutex
+		reported error
+	)
+	w := getWebhook(nil, func(_ *http.Request, err error) {
+		mu.Lock()
+		reported = err
+		mu.Unlock()
+	})
+	w.Dispatcher = NewDispatcher(1, 1)
+	w.Dedup = NewMemoryDedupStore(0)
+	started := make(chan struct{}, 3)
+	release := make(chan struct{})
+	handled := make(chan int, 3)
+	w.Bind(UpdateInvoicePaid, func(update *WebhookUpdate) {
+		started <- struct{}{}
+		<-release
+		handled <- update.Payload.Id
+	})
+
+	update := func(invoiceId int) *WebhookUpdate {
+		return &WebhookUpdate{UpdateType: UpdateInvoicePaid, Payload: Invoice{Id: invoiceId, Status: StatusPaid}}
+	}
+	if code := serveUpdate(w, update(1)); code != http.StatusOK {
+		t.Fatalf("status(%d) != 200", code)
+	}
+	// Worker is blocked in first handler, so second update fills the queue.
+	<-started
+	if code := serveUpdate(w, update(2)); code != http.StatusOK {
+		t.Fatalf("status(%d) != 200", code)
+	}
+	if code := serveUpdate(w, update(3)); code != http.StatusServiceUnavailable {
+		t.Fatalf("status(%d) != 503 on full queue", code)
+	}
+	mu.Lock()
+	if !errors.Is(reported, ErrorQueueFull) {
+		t.Errorf("err(%v) != ErrorQueueFull", reported)
+	}
+	mu.Unlock()
+
+	close(release)
+	w.Dispatcher.Close()
+	if len(handled) != 2 {
+		t.Errorf("handled(%d) != 2", len(handled))
+	}
+	// Rejected update isn't marked as seen, so redelivery is handled.
+	w.Dispatcher = NewDispatcher(1, 1)
+	if code := serveUpdate(w, update(3)); code != http.StatusOK {
+		t.Errorf("redelivery: status(%d) != 200", code)
+	}
+	w.Dispatcher.Close()
+	if len(handled) != 3 {
+		t.Errorf("handled(%d) != 3 after redelivery", len(handled))
+	}
+}
+
+func TestWebhook_ShutdownDispatcher(t *testing.T) {
+	t.Run("owned", func(t *testing.T) {
+		w := NewClient(ClientSettings{Token: "5675:test_token", Webhook: WebhookSettings{Workers: 2, QueueSize: 1}}).Webhook()
+		handled := make(chan struct{}, 1)
+		w.Bind(UpdateInvoicePaid, func(*WebhookUpdate) {
+			time.Sleep(10 * time.Millisecond)
+			handled <- struct{}{}
+		})
+		if code := serveUpdate(w, &WebhookUpdate{UpdateType: UpdateInvoicePaid}); code != http.StatusOK {
+			t.Fatalf("status(%d) != 200", code)
+		}
+		if _, err := w.Shutdown(context.Background()); err != nil {
+			t.Fatal(err)
+		}
+		if len(handled) != 1 {
+			t.Error("Shutdown returned before handler finished")
+		}
+		if w.Dispatcher.Dispatch(&WebhookUpdate{}, nil) {
+			t.Error("dispatcher created by NewClient isn't closed by Shutdown")
+		}
+	})
+
+	t.Run("not owned", func(t *testing.T) {
+		w := getWebhook(nil, nil)
+		w.Dispatcher = NewDispatcher(1, 1)
+		defer w.Dispatcher.Close()
+		if _, err := w.Shutdown(context.Background()); err != nil {
+			t.Fatal(err)
+		}
+		if !w.Dispatcher.Dispatch(&WebhookUpdate{}, nil) {
+			t.Error("dispatcher set by caller is closed by Shutdown")
+		}
+	})
+}
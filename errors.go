@@ -0,0 +1,71 @@
+package cryptopay
+
+import (
+	"context"
+	"errors"
+	"io"
+	"net"
+	"net/http"
+)
+
+// Known errors of API. Compare with them by errors.Is, it matches ApiError by name:
+//
+//	if errors.Is(err, cryptopay.ErrInsufficientFunds) {
+//		// top up balance of the app
+//	}
+var (
+	ErrUnauthorized       = &ApiError{Code: http.StatusUnauthorized, Name: "UNAUTHORIZED"}
+	ErrMethodNotFound     = &ApiError{Code: http.StatusMethodNotAllowed, Name: "METHOD_NOT_FOUND"}
+	ErrInsufficientFunds  = &ApiError{Code: http.StatusBadRequest, Name: "INSUFFICIENT_FUNDS"}
+	ErrAmountTooSmall     = &ApiError{Code: http.StatusBadRequest, Name: "AMOUNT_TOO_SMALL"}
+	ErrAmountTooBig       = &ApiError{Code: http.StatusBadRequest, Name: "AMOUNT_TOO_BIG"}
+	ErrExpiresInInvalid   = &ApiError{Code: http.StatusBadRequest, Name: "EXPIRES_IN_INVALID"}
+	ErrInvoiceNotFound    = &ApiError{Code: http.StatusBadRequest, Name: "INVOICE_NOT_FOUND"}
+	ErrInvoiceAlreadyPaid = &ApiError{Code: http.StatusBadRequest, Name: "INVOICE_ALREADY_PAID"}
+	ErrInvoiceExpired     = &ApiError{Code: http.StatusBadRequest, Name: "INVOICE_EXPIRED"}
+	ErrCheckNotFound      = &ApiError{Code: http.StatusBadRequest, Name: "CHECK_NOT_FOUND"}
+)
+
+// IsRetryable reports whether err is transient and request can be repeated later.
+// These are network errors, responses with 429 and 5xx status codes.
+// Cancellation and deadline of context aren't retryable.
+func IsRetryable(err error) bool {
+	if err == nil || errors.Is(err, context.Canceled) || errors.Is(err, context.DeadlineExceeded) {
+		return false
+	}
+	if apiErr := GetApiError(err); apiErr != nil {
+		return retryableCode(apiErr.Code)
+	}
+	if transportErr := GetTransportError(err); transportErr != nil {
+		return retryableCode(transportErr.StatusCode)
+	}
+	var netErr net.Error
+	return errors.As(err, &netErr) || errors.Is(err, io.ErrUnexpectedEOF)
+}
+
+// IsAuthError reports whether err is caused by invalid token of the app.
+func IsAuthError(err error) bool {
+	if apiErr := GetApiError(err); apiErr != nil {
+		return apiErr.Code == http.StatusUnauthorized || apiErr.Name == ErrUnauthorized.Name
+	}
+	if transportErr := GetTransportError(err); transportErr != nil {
+		return transportErr.StatusCode == http.StatusUnauthorized
+	}
+	return false
+}
+
+// IsClientError reports whether err is caused by invalid request, so it must not be repeated as is.
+// These are API errors with 4xx status codes (except 429) and errors of client-side checks of options.
+func IsClientError(err error) bool {
+	if apiErr := GetApiError(err); apiErr != nil {
+		return apiErr.Code >= 400 && apiErr.Code < 500 && apiErr.Code != http.StatusTooManyRequests
+	}
+	return GetValidationError(err) != nil ||
+		errors.Is(err, ErrorCurrencyMismatch) ||
+		errors.Is(err, ErrorCheckPinConflict)
+}
+
+// retryableCode reports whether HTTP status code means transient failure.
+func retryableCode(code int) bool {
+	return code == http.StatusTooManyRequests || code >= 500
+}
@@ -0,0 +1,71 @@
+package cryptopay
+
+import (
+	"context"
+	"errors"
+	"fmt"
+	"io"
+	"net"
+	"net/url"
+	"testing"
+)
+
+func TestApiError_Is(t *testing.T) {
+	err := fmt.Errorf("wrapped: %w", &ApiError{Code: 400, Name: "INSUFFICIENT_FUNDS"})
+	if !errors.Is(err, ErrInsufficientFunds) {
+		t.Error("error not matched by name")
+	}
+	if errors.Is(err, ErrAmountTooSmall) || errors.Is(&ApiError{Code: 400}, &ApiError{Code: 400}) {
+		t.Error("different errors matched")
+	}
+
+	c := getClient()
+	if err := c.DeleteInvoice(42); !errors.Is(err, ErrInvoiceNotFound) {
+		t.Errorf("err(%v) != ErrInvoiceNotFound", err)
+	}
+	if err := c.DeleteInvoice(0); !errors.Is(err, ErrInvoiceAlreadyPaid) {
+		t.Errorf("err(%v) != ErrInvoiceAlreadyPaid", err)
+	}
+}
+
+func TestErrorClassification(t *testing.T) {
+	netErr := &url.Error{Op: "Post", URL: "https://pay.crypt.bot", Err: &net.OpError{Op: "dial", Err: errors.New("refused")}}
+	var cases = []struct {
+		name      string
+		err       error
+		retryable bool
+		auth      bool
+		client    bool
+	}{
+		{name: "nil"},
+		{name: "insufficient funds", err: ErrInsufficientFunds, client: true},
+		{name: "unauthorized", err: &ApiError{Code: 401, Name: "UNAUTHORIZED"}, auth: true, client: true},
+		{name: "too many requests", err: &ApiError{Code: 429, Name: "TOO_MANY_REQUESTS"}, retryable: true},
+		{name: "api internal", err: &ApiError{Code: 500, Name: "INTERNAL_ERROR"}, retryable: true},
+		{name: "bad gateway", err: &TransportError{StatusCode: 502}, retryable: true},
+		{name: "transport unauthorized", err: &TransportError{StatusCode: 401}, auth: true},
+		{name: "network", err: netErr, retryable: true},
+		{name: "unexpected eof", err: io.ErrUnexpectedEOF, retryable: true},
+		{name: "canceled", err: &url.Error{Op: "Post", Err: context.Canceled}},
+		{name: "validation", err: &ValidationError{}, client: true},
+		{name: "pin conflict", err: ErrorCheckPinConflict, client: true},
+		{
+			name:   "invoice state",
+			err:    &InvoiceStateError{Status: StatusPaid, Err: ErrInvoiceAlreadyPaid},
+			client: true,
+		},
+	}
+	for _, tc := range cases {
+		t.Run(tc.name, func(t *testing.T) {
+			if got := IsRetryable(tc.err); got != tc.retryable {
+				t.Errorf("IsRetryable = %v", got)
+			}
+			if got := IsAuthError(tc.err); got != tc.auth {
+				t.Errorf("IsAuthError = %v", got)
+			}
+			if got := IsClientError(tc.err); got != tc.client {
+				t.Errorf("IsClientError = %v", got)
+			}
+		})
+	}
+}
@@ -0,0 +1,98 @@
+package cryptopay
+
+import (
+	"context"
+	"fmt"
+	"net/http"
+	"runtime/debug"
+	"time"
+)
+
+// Middleware wraps dispatch of verified update to all handlers. It can inspect update, change context,
+// return error without call of next (then webhook responds with non-2xx status code) or
+// return nil without call of next (then update is acknowledged, but handlers are not called).
+//
+// Next returns when HandlerFunc handlers and Handler handlers are finished. Webhook responds to API
+// as soon as Handler handlers are started, so error returned after that is only passed to Webhook.OnError.
+type Middleware func(next HandlerFunc) HandlerFunc
+
+// requestContextKey is key of webhook request in context of handlers.
+type requestContextKey struct{}
+
+// RequestFromContext returns webhook request from context of handlers and middlewares.
+// It's useful for checks of source IP or headers.
+func RequestFromContext(ctx context.Context) (*http.Request, bool) {
+	r, ok := ctx.Value(requestContextKey{}).(*http.Request)
+	return r, ok
+}
+
+// Use adds middlewares to Webhook. Middlewares are called in order of adding, first is outermost.
+func (w *Webhook) Use(middlewares ...Middleware) {
+	w.mu.Lock()
+	defer w.mu.Unlock()
+	w.middlewares = append(w.middlewares, middlewares...)
+}
+
+// PanicError is returned by handler chain if handler panicked.
+// If it's returned after response, it's passed to Webhook.OnPanic or Webhook.OnError.
+type PanicError struct {
+	Update *WebhookUpdate // Update which was processed.
+	Value  interface{}    // Value passed to panic.
+	Stack  []byte         // Stack trace of goroutine at the moment of panic.
+}
+
+func (e PanicError) Error() string {
+	return fmt.Sprintf("crypto-pay/webhook: handler panicked: %v", e.Value)
+}
+
+// Recover returns middleware that recovers panics of next middlewares and HandlerFunc handlers
+// and returns them as *PanicError. So webhook responds with 500 status code and calls OnError
+// instead of crash of the program. Handler handlers run in other goroutines, webhook always recovers them
+// and next returns their first panic as *PanicError.
+func Recover() Middleware {
+	return func(next HandlerFunc) HandlerFunc {
+		return func(ctx context.Context, update *WebhookUpdate) (err error) {
+			defer func() {
+				if v := recover(); v != nil {
+					err = &PanicError{Update: update, Value: v, Stack: debug.Stack()}
+				}
+			}()
+			return next(ctx, update)
+		}
+	}
+}
+
+// recoverPanic calls asynchronous handler and returns its panic as *PanicError.
+func recoverPanic(update *WebhookUpdate, handler Handler) (err error) {
+	defer func() {
+		if v := recover(); v != nil {
+			err = &PanicError{Update: update, Value: v, Stack: debug.Stack()}
+		}
+	}()
+	handler(update)
+	return nil
+}
+
+// Logging returns middleware that logs every update with result of its handling.
+// Result and duration cover all handlers, including asynchronous ones.
+// Log function receives message and alternating keys and values, like in most structured loggers.
+func Logging(log func(msg string, keysAndValues ...interface{})) Middleware {
+	return func(next HandlerFunc) HandlerFunc {
+		return func(ctx context.Context, update *WebhookUpdate) error {
+			start := time.Now()
+			err := next(ctx, update)
+			kv := []interface{}{
+				"update_id", update.Id,
+				"update_type", update.UpdateType,
+				"invoice_id", update.Payload.Id,
+				"duration", time.Since(start),
+			}
+			if err != nil {
+				log("crypto-pay/webhook: update handling failed", append(kv, "error", err)...)
+			} else {
+				log("crypto-pay/webhook: update handled", kv...)
+			}
+			return err
+		}
+	}
+}
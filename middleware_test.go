@@ -0,0 +1,280 @@
+package cryptopay
+
+import (
+	"context"
+	"errors"
+	"fmt"
+	"net/http"
+	"net/http/httptest"
+	"reflect"
+	"strings"
+	"sync"
+	"testing"
+	"time"
+)
+
+func serveUpdate(w *Webhook, update *WebhookUpdate) int {
+	req, _ := NewSignedRequest("5675:test_token", update)
+	req.RemoteAddr = "10.0.0.1:443"
+	rw := httptest.NewRecorder()
+	w.ServeHTTP(rw, req)
+	return rw.Code
+}
+
+func TestWebhook_Use(t *testing.T) {
+	update := &WebhookUpdate{Id: 1, UpdateType: UpdateInvoicePaid}
+	trace := func(calls *[]string, name string) Middleware {
+		return func(next HandlerFunc) HandlerFunc {
+			return func(ctx context.Context, update *WebhookUpdate) error {
+				*calls = append(*calls, name)
+				return next(ctx, update)
+			}
+		}
+	}
+
+	t.Run("order", func(t *testing.T) {
+		var calls []string
+		w := getWebhook(nil, nil)
+		w.Use(trace(&calls, "first"), trace(&calls, "second"))
+		w.Handle(UpdateInvoicePaid, func(ctx context.Context, update *WebhookUpdate) error {
+			calls = append(calls, "handler")
+			return nil
+		})
+		if code := serveUpdate(w, update); code != http.StatusOK {
+			t.Errorf("status(%d) != 200", code)
+		}
+		if !reflect.DeepEqual(calls, []string{"first", "second", "handler"}) {
+			t.Errorf("unexpected calls %v", calls)
+		}
+	})
+	t.Run("source ip", func(t *testing.T) {
+		handled := make(chan struct{}, 1)
+		w := getWebhook(nil, nil)
+		w.Use(func(next HandlerFunc) HandlerFunc {
+			return func(ctx context.Context, update *WebhookUpdate) error {
+				r, ok := RequestFromContext(ctx)
+				if !ok {
+					return errors.New("no request")
+				}
+				if r.RemoteAddr != "10.0.0.1:443" {
+					return &HandlerError{StatusCode: http.StatusForbidden, Err: errors.New("unknown source")}
+				}
+				return nil
+			}
+		})
+		w.Bind(UpdateInvoicePaid, func(_ *WebhookUpdate) {
+			handled <- struct{}{}
+		})
+		if code := serveUpdate(w, update); code != http.StatusOK {
+			t.Errorf("status(%d) != 200", code)
+		}
+		select {
+		case <-handled:
+			t.Error("handler called after middleware stopped chain")
+		default:
+		}
+	})
+	t.Run("error", func(t *testing.T) {
+		w := getWebhook(nil, nil)
+		w.Use(func(next HandlerFunc) HandlerFunc {
+			return func(ctx context.Context, update *WebhookUpdate) error {
+				return &HandlerError{StatusCode: http.StatusForbidden, Err: errors.New("unknown source")}
+			}
+		})
+		if code := serveUpdate(w, update); code != http.StatusForbidden {
+			t.Errorf("status(%d) != 403", code)
+		}
+	})
+}
+
+func TestRecover(t *testing.T) {
+	var reported error
+	w := getWebhook(nil, func(_ *http.Request, err error) {
+		reported = err
+	})
+	w.Use(Recover())
+	w.Handle(UpdateInvoicePaid, func(ctx context.Context, update *WebhookUpdate) error {
+		panic("boom")
+	})
+	if code := serveUpdate(w, &WebhookUpdate{Id: 7, UpdateType: UpdateInvoicePaid}); code != http.StatusInternalServerError {
+		t.Errorf("status(%d) != 500", code)
+	}
+	var panicErr *PanicError
+	if !errors.As(reported, &panicErr) {
+		t.Fatalf("err(%v) is not PanicError", reported)
+	}
+	if panicErr.Value != "boom" || panicErr.Update.Id != 7 || len(panicErr.Stack) == 0 {
+		t.Errorf("unexpected error %#v", panicErr)
+	}
+}
+
+func TestLogging(t *testing.T) {
+	var logs []string
+	w := getWebhook(nil, nil)
+	w.Use(Logging(func(msg string, kv ...interface{}) {
+		logs = append(logs, fmt.Sprint(append([]interface{}{msg}, kv[:6]...)...))
+		if len(kv)%2 != 0 {
+			t.Errorf("odd count of keys and values: %v", kv)
+		}
+	}))
+	var fail bool
+	w.Handle(UpdateInvoicePaid, func(ctx context.Context, update *WebhookUpdate) error {
+		if fail {
+			return errors.New("failed")
+		}
+		return nil
+	})
+	update := &WebhookUpdate{Id: 3, UpdateType: UpdateInvoicePaid, Payload: Invoice{Id: 9}}
+	serveUpdate(w, update)
+	fail = true
+	serveUpdate(w, update)
+	expected := []string{
+		"crypto-pay/webhook: update handledupdate_id3update_typeinvoice_paidinvoice_id9",
+		"crypto-pay/webhook: update handling failedupdate_id3update_typeinvoice_paidinvoice_id9",
+	}
+	if !reflect.DeepEqual(logs, expected) {
+		t.Errorf("unexpected logs %q", logs)
+	}
+}
+
+func TestWebhook_PanicIsolation(t *testing.T) {
+	for _, withDispatcher := range []bool{false, true} {
+		name := "goroutines"
+		if withDispatcher {
+			name = "dispatcher"
+		}
+		t.Run(name, func(t *testing.T) {
+			panics := make(chan *PanicError, 1)
+			w := getWebhook(nil, func(_ *http.Request, err error) {
+				t.Errorf("unexpected error %v", err)
+			})
+			w.OnPanic = func(_ *http.Request, err *PanicError) {
+				panics <- err
+			}
+			if withDispatcher {
+				w.Dispatcher = NewDispatcher(1, 1)
+				defer w.Dispatcher.Close()
+			}
+			handled := make(chan int, 2)
+			w.Bind(UpdateInvoicePaid, func(update *WebhookUpdate) {
+				if update.Id == 1 {
+					panic("boom")
+				}
+				handled <- update.Id
+			})
+			w.Bind(UpdateInvoicePaid, func(update *WebhookUpdate) {
+				handled <- -update.Id
+			})
+
+			if code := serveUpdate(w, &WebhookUpdate{Id: 1, UpdateType: UpdateInvoicePaid}); code != http.StatusOK {
+				t.Fatalf("status(%d) != 200", code)
+			}
+			select {
+			case err := <-panics:
+				if err.Value != "boom" || err.Update.Id != 1 || !strings.Contains(string(err.Stack), "panic") {
+					t.Errorf("unexpected panic error %#v", err)
+				}
+			case <-time.After(time.Second):
+				t.Fatal("panic not reported")
+			}
+			if id := <-handled; id != -1 {
+				t.Errorf("other handler got %d, expected -1", id)
+			}
+
+			// Worker survives panic.
+			serveUpdate(w, &WebhookUpdate{Id: 2, UpdateType: UpdateInvoicePaid})
+			if _, err := w.Shutdown(context.Background()); err != nil {
+				t.Fatal(err)
+			}
+			if got := len(handled); got != 2 {
+				t.Errorf("handled(%d) != 2", got)
+			}
+		})
+	}
+}
+
+func TestWebhook_PanicSync(t *testing.T) {
+	var reported error
+	w := getWebhook(nil, func(_ *http.Request, err error) {
+		reported = err
+	})
+	w.Handle(UpdateInvoicePaid, func(ctx context.Context, update *WebhookUpdate) error {
+		panic("boom")
+	})
+	if code := serveUpdate(w, &WebhookUpdate{Id: 1, UpdateType: UpdateInvoicePaid}); code != http.StatusInternalServerError {
+		t.Errorf("status(%d) != 500", code)
+	}
+	var panicErr *PanicError
+	if !errors.As(reported, &panicErr) || panicErr.Update.Id != 1 {
+		t.Errorf("err(%v) is not PanicError", reported)
+	}
+}
+
+func TestWebhook_UseBind(t *testing.T) {
+	var (
+		mu     sync.Mutex
+		events []string
+	)
+	record := func(event string) {
+		mu.Lock()
+		events = append(events, event)
+		mu.Unlock()
+	}
+	chainDone := make(chan error, 1)
+	w := getWebhook(nil, func(_ *http.Request, err error) {
+		t.Errorf("unexpected error %v", err)
+	})
+	w.OnPanic = func(_ *http.Request, err *PanicError) {
+		record("on panic")
+	}
+	w.Use(func(next HandlerFunc) HandlerFunc {
+		return func(ctx context.Context, update *WebhookUpdate) error {
+			if update.Id == 0 {
+				return nil
+			}
+			record("before")
+			err := next(ctx, update)
+			record("after")
+			chainDone <- err
+			return nil
+		}
+	})
+	release := make(chan struct{})
+	w.Bind(UpdateInvoicePaid, func(update *WebhookUpdate) {
+		<-release
+		record("handler")
+		if update.Id == 2 {
+			panic("boom")
+		}
+	})
+
+	if code := serveUpdate(w, &WebhookUpdate{Id: 0, UpdateType: UpdateInvoicePaid}); code != http.StatusOK {
+		t.Fatalf("status(%d) != 200", code)
+	}
+	// Response doesn't wait for asynchronous handler.
+	if code := serveUpdate(w, &WebhookUpdate{Id: 1, UpdateType: UpdateInvoicePaid}); code != http.StatusOK {
+		t.Fatalf("status(%d) != 200", code)
+	}
+	close(release)
+	if err := <-chainDone; err != nil {
+		t.Errorf("unexpected chain error %v", err)
+	}
+	if expected := []string{"before", "handler", "after"}; !reflect.DeepEqual(events, expected) {
+		t.Errorf("events %v != %v, middleware skipped by update 0 must not run handler", events, expected)
+	}
+
+	events = nil
+	serveUpdate(w, &WebhookUpdate{Id: 2, UpdateType: UpdateInvoicePaid})
+	var panicErr *PanicError
+	if err := <-chainDone; !errors.As(err, &panicErr) || panicErr.Value != "boom" {
+		t.Errorf("err(%v) is not panic of handler", err)
+	}
+	if _, err := w.Shutdown(context.Background()); err != nil {
+		t.Fatal(err)
+	}
+	mu.Lock()
+	defer mu.Unlock()
+	if expected := []string{"before", "handler", "after"}; !reflect.DeepEqual(events, expected) {
+		t.Errorf("events %v != %v, panic returned by chain must not be reported twice", events, expected)
+	}
+}
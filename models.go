@@ -19,6 +19,42 @@ const (
 	BUSD Asset = "BUSD"
 )
 
+// Fiat is fiat currency code.
+type Fiat string
+
+//goland:noinspection ALL
+const (
+	USD Fiat = "USD"
+	EUR Fiat = "EUR"
+	RUB Fiat = "RUB"
+	BYN Fiat = "BYN"
+	UAH Fiat = "UAH"
+	GBP Fiat = "GBP"
+	CNY Fiat = "CNY"
+	KZT Fiat = "KZT"
+	UZS Fiat = "UZS"
+	GEL Fiat = "GEL"
+	TRY Fiat = "TRY"
+	AMD Fiat = "AMD"
+	THB Fiat = "THB"
+	INR Fiat = "INR"
+	BRL Fiat = "BRL"
+	IDR Fiat = "IDR"
+	AZN Fiat = "AZN"
+	AED Fiat = "AED"
+	PLN Fiat = "PLN"
+	ILS Fiat = "ILS"
+)
+
+// CurrencyType is type of the price of the invoice.
+type CurrencyType string
+
+//goland:noinspection ALL
+const (
+	CurrencyCrypto CurrencyType = "crypto"
+	CurrencyFiat   CurrencyType = "fiat"
+)
+
 // PaidButton is name of the button that will be shown to a user after the invoice is paid.
 type PaidButton string
 
@@ -40,6 +76,15 @@ const (
 	StatusExpired InvoiceStatus = "expired"
 )
 
+// CheckStatus is status of the check.
+type CheckStatus string
+
+//goland:noinspection ALL
+const (
+	CheckStatusActive    CheckStatus = "active"
+	CheckStatusActivated CheckStatus = "activated"
+)
+
 type (
 	// ApiError is error of CryptoPay API.
 	ApiError struct {
@@ -56,47 +101,82 @@ type (
 
 	// Invoice object.
 	Invoice struct {
-		Id              int           `json:"invoice_id"`                 // Unique ID for this invoice.
-		Status          InvoiceStatus `json:"status"`                     // Status of the invoice, can be either .
-		Hash            string        `json:"hash,omitempty"`             // Hash of the invoice.
-		Asset           Asset         `json:"asset"`                      // Currency code.
-		Amount          string        `json:"amount"`                     // Amount of the invoice.
-		Fee             string        `json:"fee"`                        // Optional. Amount of charged service fees.
-		PayUrl          string        `json:"pay_url,omitempty"`          // URL should be presented to the user to pay the invoice.
-		CreatedAt       time.Time     `json:"created_at"`                 // Date the invoice was created in ISO 8601 format.
-		USDRate         string        `json:"usd_rate"`                   // Optional. Price of the Asset in USD at the time the invoice was paid.
-		AllowComments   bool          `json:"allow_comments,omitempty"`   // True, if the user can add comment to the payment.
-		AllowAnonymous  bool          `json:"allow_anonymous,omitempty"`  // True, if the user can pay the invoice anonymously.
-		PaidAt          time.Time     `json:"paid_at,omitempty"`          // Optional. Date the invoice was paid in Unix time.
-		PaidAnonymously bool          `json:"paid_anonymously,omitempty"` // Optional. Text of the hidden message for this invoice.
-		Description     string        `json:"description,omitempty"`      // Optional. Description for this invoice.
-		ExpirationDate  string        `json:"expiration_date,omitempty"`  // Optional. Date the invoice expires in Unix time. (not timestamp)
-		Comment         string        `json:"comment,omitempty"`          // Optional. Comment to the payment from the user.
-		HiddenMessage   string        `json:"hidden_message,omitempty"`   // Optional. Text of the hidden message for this invoice.
-		Payload         string        `json:"payload,omitempty"`          // Optional. Previously provided data for this invoice.
-		PaidBtnName     PaidButton    `json:"paid_btn_name,omitempty"`    // Optional. Name of the button.
-		PaidBtnUrl      string        `json:"paid_btn_url,omitempty"`     // Optional. URL of the button.
+		Id               int           `json:"invoice_id"`                   // Unique ID for this invoice.
+		Status           InvoiceStatus `json:"status"`                       // Status of the invoice, can be either .
+		Hash             string        `json:"hash,omitempty"`               // Hash of the invoice.
+		CurrencyType     CurrencyType  `json:"currency_type,omitempty"`      // Type of the price, can be "crypto" or "fiat".
+		Asset            Asset         `json:"asset,omitempty"`              // Currency code. Available only if the value of the field CurrencyType is "crypto".
+		Fiat             Fiat          `json:"fiat,omitempty"`               // Fiat currency code. Available only if the value of the field CurrencyType is "fiat".
+		Amount           Amount        `json:"amount"`                       // Amount of the invoice.
+		PaidAsset        Asset         `json:"paid_asset,omitempty"`         // Optional. Cryptocurrency alphabetic code for which the invoice was paid.
+		PaidAmount       Amount        `json:"paid_amount,omitempty"`        // Optional. Amount of the invoice for which the invoice was paid.
+		PaidFiatRate     Amount        `json:"paid_fiat_rate,omitempty"`     // Optional. The rate of the PaidAsset valued in the fiat currency.
+		AcceptedAssets   []Asset       `json:"accepted_assets,omitempty"`    // Optional. List of assets which can be used to pay the invoice. Available only for fiat invoices.
+		FeeAsset         Asset         `json:"fee_asset,omitempty"`          // Optional. Asset of service fees charged when the invoice was paid.
+		Fee              Amount        `json:"fee"`                          // Optional. Amount of charged service fees.
+		FeeInUsd         Amount        `json:"fee_in_usd,omitempty"`         // Optional. Amount of service fees charged when the invoice was paid in USD.
+		PayUrl           string        `json:"pay_url,omitempty"`            // URL should be presented to the user to pay the invoice.
+		CreatedAt        time.Time     `json:"created_at"`                   // Date the invoice was created in ISO 8601 format.
+		USDRate          Amount        `json:"usd_rate"`                     // Optional. Price of the Asset in USD at the time the invoice was paid.
+		AllowComments    bool          `json:"allow_comments,omitempty"`     // True, if the user can add comment to the payment.
+		AllowAnonymous   bool          `json:"allow_anonymous,omitempty"`    // True, if the user can pay the invoice anonymously.
+		PaidAt           time.Time     `json:"paid_at,omitempty"`            // Optional. Date the invoice was paid in Unix time.
+		PaidAnonymously  bool          `json:"paid_anonymously,omitempty"`   // Optional. Text of the hidden message for this invoice.
+		Description      string        `json:"description,omitempty"`        // Optional. Description for this invoice.
+		ExpirationDate   string        `json:"expiration_date,omitempty"`    // Optional. Date the invoice expires in Unix time. (not timestamp)
+		Comment          string        `json:"comment,omitempty"`            // Optional. Comment to the payment from the user.
+		HiddenMessage    string        `json:"hidden_message,omitempty"`     // Optional. Text of the hidden message for this invoice.
+		Payload          string        `json:"payload,omitempty"`            // Optional. Previously provided data for this invoice.
+		PaidBtnName      PaidButton    `json:"paid_btn_name,omitempty"`      // Optional. Name of the button.
+		PaidBtnUrl       string        `json:"paid_btn_url,omitempty"`       // Optional. URL of the button.
+		IsSwapped        bool          `json:"is_swapped,omitempty"`         // Optional. True, if the paid amount was swapped to SwappedTo asset.
+		SwappedTo        Asset         `json:"swapped_to,omitempty"`         // Optional. Asset to which the paid amount was swapped.
+		SwappedRate      Amount        `json:"swapped_rate,omitempty"`       // Optional. Rate of the paid asset valued in SwappedTo asset.
+		SwappedOutput    Amount        `json:"swapped_output,omitempty"`     // Optional. Amount in SwappedTo asset received as a result of swap.
+		SwappedUsdAmount Amount        `json:"swapped_usd_amount,omitempty"` // Optional. Resulting swapped amount in USD.
 	}
 	// Transfer object
 	Transfer struct {
-		Id          int       `json:"transfer_id"`       // Unique ID for this transfer.
-		UserId      int       `json:"user_id"`           // Telegram user ID the transfer was sent to.
-		Asset       Asset     `json:"asset"`             // Currency code.
-		Amount      string    `json:"amount"`            // Amount of the transfer.
-		Status      string    `json:"status"`            // Status of the transfer, can be “completed”.
-		CompletedAt time.Time `json:"completed_at"`      // Date the transfer was completed in ISO 8601 format.
-		Comment     string    `json:"comment,omitempty"` // Optional. Comment for this transfer.
+		Id          int       `json:"transfer_id"`        // Unique ID for this transfer.
+		SpendId     string    `json:"spend_id,omitempty"` // Unique UTF-8 string passed on transfer creation.
+		UserId      int       `json:"user_id"`            // Telegram user ID the transfer was sent to.
+		Asset       Asset     `json:"asset"`              // Currency code.
+		Amount      Amount    `json:"amount"`             // Amount of the transfer.
+		Status      string    `json:"status"`             // Status of the transfer, can be “completed”.
+		CompletedAt time.Time `json:"completed_at"`       // Date the transfer was completed in ISO 8601 format.
+		Comment     string    `json:"comment,omitempty"`  // Optional. Comment for this transfer.
+	}
+	// Check object.
+	Check struct {
+		Id          int         `json:"check_id"`               // Unique ID for this check.
+		Hash        string      `json:"hash"`                   // Hash of the check.
+		Asset       Asset       `json:"asset"`                  // Currency code.
+		Amount      Amount      `json:"amount"`                 // Amount of the check.
+		BotCheckUrl string      `json:"bot_check_url"`          // URL should be provided to the user to activate the check.
+		Status      CheckStatus `json:"status"`                 // Status of the check, can be "active" or "activated".
+		CreatedAt   time.Time   `json:"created_at"`             // Date the check was created in ISO 8601 format.
+		ActivatedAt time.Time   `json:"activated_at,omitempty"` // Optional. Date the check was activated in ISO 8601 format.
+	}
+	// AppStats object contains statistics of the app for the period.
+	AppStats struct {
+		Volume              Amount    `json:"volume"`                // Total volume of paid invoices in USD.
+		Conversion          float64   `json:"conversion"`            // Conversion of all created invoices.
+		UniqueUsersCount    int       `json:"unique_users_count"`    // The unique number of users who have paid the invoice.
+		CreatedInvoiceCount int       `json:"created_invoice_count"` // Total created invoice count.
+		PaidInvoiceCount    int       `json:"paid_invoice_count"`    // Total paid invoice count.
+		StartAt             time.Time `json:"start_at"`              // The date on which the statistics calculation was started in ISO 8601 format.
+		EndAt               time.Time `json:"end_at"`                // The date on which the statistics calculation was ended in ISO 8601 format.
 	}
 	// BalanceCurrency  contains information about available funds for a particular currency.
 	BalanceCurrency struct {
 		CurrencyCode Asset  `json:"currency_code"`
-		Available    string `json:"available"` // Balance
+		Available    Amount `json:"available"` // Balance
 	}
 	ExchangeRate struct {
 		IsValid bool   `json:"is_valid"` // Indicates valid exchange
 		Source  Asset  `json:"source"`   // Source currency
 		Target  Asset  `json:"target"`   // Target currency
-		Rate    string `json:"rate"`     // Cost Target in Source currency
+		Rate    Amount `json:"rate"`     // Cost Target in Source currency
 	}
 	CurrencyInfo struct {
 		IsBlockchain bool   `json:"is_blockchain"` // Indicates what currency is crypto.
@@ -122,6 +202,31 @@ func (a ApiError) Error() string {
 	return fmt.Sprintf("crypto-pay/api: api response %d - %s", a.Code, a.Name)
 }
 
+// Is reports whether target is ApiError with same name. It allows to use errors.Is with ErrInsufficientFunds and others.
+func (a ApiError) Is(target error) bool {
+	t, ok := target.(*ApiError)
+	return ok && t != nil && t.Name != "" && t.Name == a.Name
+}
+
+// InvoiceStateError is returned if operation can't be applied to invoice because of its status.
+// For example, paid or expired invoice can't be deleted.
+//
+// Underlying ApiError is available through errors.As and GetApiError.
+type InvoiceStateError struct {
+	InvoiceId int           // ID of the invoice.
+	Status    InvoiceStatus // Current status of the invoice.
+	Err       *ApiError     // Original error of API.
+}
+
+func (e InvoiceStateError) Error() string {
+	return fmt.Sprintf("crypto-pay/api: invoice %d is %s", e.InvoiceId, e.Status)
+}
+
+func (e InvoiceStateError) Unwrap() error { return e.Err }
+
 func (a Asset) String() string         { return string(a) }
+func (f Fiat) String() string          { return string(f) }
+func (c CurrencyType) String() string  { return string(c) }
 func (p PaidButton) String() string    { return string(p) }
 func (i InvoiceStatus) String() string { return string(i) }
+func (c CheckStatus) String() string   { return string(c) }
@@ -0,0 +1,169 @@
+package cryptopay
+
+import (
+	"context"
+	"net/http"
+	"strconv"
+	"sync"
+	"time"
+)
+
+// RateBudget is rate of requests: Rate requests per second with bursts up to Burst requests.
+// Zero Rate means no limit.
+type RateBudget struct {
+	// Rate is count of requests per second.
+	Rate float64
+	// Burst is maximum count of requests that can be made at once. Default 1.
+	Burst int
+}
+
+// RateLimitSettings configures client-side rate limiter of ApiCore.
+//
+// Request must fit both into budget of its method and into global budget.
+// Also, if API responses with 429 status code, all requests are paused for Retry-After duration.
+type RateLimitSettings struct {
+	// Global is budget for all requests.
+	Global RateBudget
+	// Methods is budgets per API method, key is name of method (constants Method*, for example MethodCreateInvoice).
+	Methods map[string]RateBudget
+}
+
+// rateLimiter is set of token buckets for ApiCore. It's safe for concurrent use.
+// Nil rateLimiter doesn't limit anything.
+type rateLimiter struct {
+	global  *tokenBucket
+	methods map[string]*tokenBucket
+
+	mu          sync.Mutex
+	pausedUntil time.Time
+}
+
+// newRateLimiter returns rateLimiter for settings. Buckets are created only for budgets with positive rate.
+func newRateLimiter(settings RateLimitSettings) *rateLimiter {
+	l := &rateLimiter{
+		global:  newTokenBucket(settings.Global),
+		methods: make(map[string]*tokenBucket),
+	}
+	for method, budget := range settings.Methods {
+		if b := newTokenBucket(budget); b != nil {
+			l.methods[method] = b
+		}
+	}
+	return l
+}
+
+// wait blocks until request to method is allowed or ctx is done.
+func (l *rateLimiter) wait(ctx context.Context, method string) error {
+	if l == nil {
+		return nil
+	}
+	for {
+		l.mu.Lock()
+		d := time.Until(l.pausedUntil)
+		l.mu.Unlock()
+		if d <= 0 {
+			break
+		}
+		if err := sleep(ctx, d); err != nil {
+			return err
+		}
+	}
+	bucket := l.methods[method]
+	if err := bucket.wait(ctx); err != nil {
+		return err
+	}
+	if err := l.global.wait(ctx); err != nil {
+		// Request isn't made, so token of method must not be spent.
+		bucket.refund()
+		return err
+	}
+	return nil
+}
+
+// pause stops all requests for given duration.
+func (l *rateLimiter) pause(d time.Duration) {
+	if l == nil || d <= 0 {
+		return
+	}
+	until := time.Now().Add(d)
+	l.mu.Lock()
+	if until.After(l.pausedUntil) {
+		l.pausedUntil = until
+	}
+	l.mu.Unlock()
+}
+
+// tokenBucket is classic token bucket. Nil tokenBucket doesn't limit anything.
+type tokenBucket struct {
+	mu     sync.Mutex
+	rate   float64
+	burst  float64
+	tokens float64
+	last   time.Time
+}
+
+func newTokenBucket(budget RateBudget) *tokenBucket {
+	if budget.Rate <= 0 {
+		return nil
+	}
+	burst := float64(budget.Burst)
+	if burst < 1 {
+		burst = 1
+	}
+	return &tokenBucket{rate: budget.Rate, burst: burst, tokens: burst, last: time.Now()}
+}
+
+// wait reserves token and waits until it's available.
+// If ctx is done before that, token is returned to the bucket.
+func (b *tokenBucket) wait(ctx context.Context) error {
+	if b == nil {
+		return nil
+	}
+	b.mu.Lock()
+	now := time.Now()
+	b.tokens += now.Sub(b.last).Seconds() * b.rate
+	if b.tokens > b.burst {
+		b.tokens = b.burst
+	}
+	b.last = now
+	b.tokens--
+	var d time.Duration
+	if b.tokens < 0 {
+		d = time.Duration(-b.tokens / b.rate * float64(time.Second))
+	}
+	b.mu.Unlock()
+
+	if d == 0 {
+		return nil
+	}
+	if err := sleep(ctx, d); err != nil {
+		b.refund()
+		return err
+	}
+	return nil
+}
+
+// refund returns reserved token to the bucket.
+func (b *tokenBucket) refund() {
+	if b == nil {
+		return
+	}
+	b.mu.Lock()
+	b.tokens++
+	b.mu.Unlock()
+}
+
+// parseRetryAfter returns duration from Retry-After header value.
+// Value can be count of seconds or HTTP date. Returns 0 if value is invalid.
+func parseRetryAfter(value string) time.Duration {
+	if value == "" {
+		return 0
+	}
+	if seconds, err := strconv.Atoi(value); err == nil {
+		return time.Duration(seconds) * time.Second
+	}
+	if date, err := http.ParseTime(value); err == nil {
+		return time.Until(date)
+	}
+	return 0
+}
@@ -0,0 +1,117 @@
+package cryptopay
+
+import (
+	"context"
+	"errors"
+	"net/http"
+	"net/http/httptest"
+	"sync"
+	"testing"
+	"time"
+)
+
+func TestTokenBucket_wait(t *testing.T) {
+	b := newTokenBucket(RateBudget{Rate: 100, Burst: 2})
+	start := time.Now()
+	var wg sync.WaitGroup
+	for i := 0; i < 6; i++ {
+		wg.Add(1)
+		go func() {
+			defer wg.Done()
+			if err := b.wait(context.Background()); err != nil {
+				t.Error(err)
+			}
+		}()
+	}
+	wg.Wait()
+	// 2 requests in burst, other 4 with rate 100 per second.
+	if elapsed := time.Since(start); elapsed < 35*time.Millisecond {
+		t.Errorf("6 requests took %s, rate not limited", elapsed)
+	}
+
+	ctx, cancel := context.WithCancel(context.Background())
+	cancel()
+	if err := b.wait(ctx); !errors.Is(err, context.Canceled) {
+		t.Errorf("err(%v) != context.Canceled", err)
+	}
+	if newTokenBucket(RateBudget{}) != nil {
+		t.Error("bucket created for zero rate")
+	}
+}
+
+func TestRateLimiter_pause(t *testing.T) {
+	l := newRateLimiter(RateLimitSettings{
+		Methods: map[string]RateBudget{MethodCreateInvoice: {Rate: 1}},
+	})
+	if _, ok := l.methods[MethodCreateInvoice]; !ok || l.global != nil {
+		t.Fatal("invalid buckets")
+	}
+	l.pause(30 * time.Millisecond)
+	start := time.Now()
+	if err := l.wait(context.Background(), MethodGetMe); err != nil {
+		t.Fatal(err)
+	}
+	if elapsed := time.Since(start); elapsed < 25*time.Millisecond {
+		t.Errorf("paused limiter waited only %s", elapsed)
+	}
+}
+
+func TestRateLimiter_refund(t *testing.T) {
+	l := newRateLimiter(RateLimitSettings{
+		Global:  RateBudget{Rate: 1},
+		Methods: map[string]RateBudget{MethodCreateInvoice: {Rate: 1}},
+	})
+	if err := l.wait(context.Background(), MethodGetMe); err != nil {
+		t.Fatal(err)
+	}
+	ctx, cancel := context.WithTimeout(context.Background(), 10*time.Millisecond)
+	defer cancel()
+	if err := l.wait(ctx, MethodCreateInvoice); !errors.Is(err, context.DeadlineExceeded) {
+		t.Fatalf("err(%v) != context.DeadlineExceeded", err)
+	}
+	b := l.methods[MethodCreateInvoice]
+	b.mu.Lock()
+	defer b.mu.Unlock()
+	if b.tokens < 1 {
+		t.Errorf("tokens(%f) < 1, method token not refunded", b.tokens)
+	}
+}
+
+func TestParseRetryAfter(t *testing.T) {
+	if d := parseRetryAfter("3"); d != 3*time.Second {
+		t.Errorf("parseRetryAfter(3) = %s", d)
+	}
+	date := time.Now().Add(time.Minute).UTC().Format(http.TimeFormat)
+	if d := parseRetryAfter(date); d < 58*time.Second || d > time.Minute {
+		t.Errorf("parseRetryAfter(%s) = %s", date, d)
+	}
+	if d := parseRetryAfter("soon"); d != 0 {
+		t.Errorf("parseRetryAfter(soon) = %s", d)
+	}
+}
+
+func TestApiCore_RetryAfter(t *testing.T) {
+	s := httptest.NewServer(http.HandlerFunc(func(rw http.ResponseWriter, r *http.Request) {
+		rw.Header().Set("Retry-After", "60")
+		writeJson(rw, http.StatusTooManyRequests, JSON{
+			"ok":    false,
+			"error": JSON{"code": 429, "name": "TOO_MANY_REQUESTS"},
+		})
+	}))
+	defer s.Close()
+	api := NewApi("1:test_token", s.URL, s.Client())
+	api.SetRateLimit(RateLimitSettings{Global: RateBudget{Rate: 10}})
+	me, err := api.GetMe()
+	if err != nil {
+		t.Fatal(err)
+	}
+	if me.Error == nil || me.Error.Code != 429 {
+		t.Errorf("unexpected error %v", me.Error)
+	}
+
+	ctx, cancel := context.WithTimeout(context.Background(), 10*time.Millisecond)
+	defer cancel()
+	if _, err := api.GetMeCtx(ctx); !errors.Is(err, context.DeadlineExceeded) {
+		t.Errorf("limiter not paused, err(%v)", err)
+	}
+}
@@ -0,0 +1,129 @@
+package cryptopay
+
+import (
+	"context"
+	"errors"
+	"math/rand"
+	"time"
+)
+
+// Defaults for RetryPolicy.
+const (
+	DefaultRetryBaseDelay = 200 * time.Millisecond
+	DefaultRetryMaxDelay  = 5 * time.Second
+)
+
+// nonIdempotentMethods is set of API methods which can create duplicates if retried.
+// Method `transfer` is not here, because it's idempotent due to spend_id, see RetryPolicy.attempts.
+var nonIdempotentMethods = map[string]bool{
+	MethodCreateInvoice: true,
+	MethodCreateCheck:   true,
+}
+
+// RetryPolicy configures retries of transient failures in ApiCore.
+// Zero value disables retries.
+//
+// Delay before n-th retry is BaseDelay * 2^(n-1), limited by MaxDelay and reduced by random Jitter.
+// Method transfer is retried only with SpendId, which makes it idempotent.
+type RetryPolicy struct {
+	// MaxAttempts is maximum count of attempts including the first. Values less than 2 disable retries.
+	MaxAttempts int
+	// BaseDelay is delay before first retry. Default DefaultRetryBaseDelay.
+	BaseDelay time.Duration
+	// MaxDelay is upper limit of delay between attempts. Default DefaultRetryMaxDelay.
+	MaxDelay time.Duration
+	// Jitter is fraction of delay in range [0, 1] that will be randomly subtracted from it.
+	// It spreads retries of concurrent requests in time.
+	Jitter float64
+	// RetryableStatusCodes is HTTP status codes of responses which count as transient.
+	// Default 429, 500, 502, 503 and 504.
+	RetryableStatusCodes []int
+	// RetryableError reports whether error of HTTP request is transient.
+	// Default all transport errors except cancellation and deadline of context.
+	RetryableError func(err error) bool
+	// RetryNonIdempotent allows retries of non-idempotent methods (createInvoice, createCheck).
+	// Retry of them can create duplicate if first request has reached API.
+	RetryNonIdempotent bool
+}
+
+// DefaultRetryPolicy returns RetryPolicy with 3 attempts and default delays.
+func DefaultRetryPolicy() RetryPolicy {
+	return RetryPolicy{
+		MaxAttempts: 3,
+		BaseDelay:   DefaultRetryBaseDelay,
+		MaxDelay:    DefaultRetryMaxDelay,
+		Jitter:      0.2,
+	}
+}
+
+// attempts returns count of attempts allowed for API method with given params.
+// Transfer without spend_id isn't idempotent, so it's never retried.
+func (p RetryPolicy) attempts(method string, params apiParams) int {
+	if p.MaxAttempts < 2 || (nonIdempotentMethods[method] && !p.RetryNonIdempotent) {
+		return 1
+	}
+	if opt, ok := params.(DoTransferOptions); ok && method == MethodTransfer && opt.SpendId == "" {
+		return 1
+	}
+	return p.MaxAttempts
+}
+
+// retryableStatus reports whether response with given HTTP status code can be retried.
+func (p RetryPolicy) retryableStatus(code int) bool {
+	codes := p.RetryableStatusCodes
+	if codes == nil {
+		codes = []int{429, 500, 502, 503, 504}
+	}
+	for _, c := range codes {
+		if c == code {
+			return true
+		}
+	}
+	return false
+}
+
+// retryableError reports whether request error can be retried.
+func (p RetryPolicy) retryableError(err error) bool {
+	if p.RetryableError != nil {
+		return p.RetryableError(err)
+	}
+	return !errors.Is(err, context.Canceled) && !errors.Is(err, context.DeadlineExceeded)
+}
+
+// delay returns delay before given retry, retry starts from 1.
+func (p RetryPolicy) delay(retry int) time.Duration {
+	base, max := p.BaseDelay, p.MaxDelay
+	if base <= 0 {
+		base = DefaultRetryBaseDelay
+	}
+	if max <= 0 {
+		max = DefaultRetryMaxDelay
+	}
+	d := base
+	for i := 1; i < retry && d < max; i++ {
+		d *= 2
+	}
+	if d > max {
+		d = max
+	}
+	if p.Jitter > 0 {
+		jitter := p.Jitter
+		if jitter > 1 {
+			jitter = 1
+		}
+		d -= time.Duration(rand.Float64() * jitter * float64(d))
+	}
+	return d
+}
+
+// sleep waits given duration or until ctx is done.
+func sleep(ctx context.Context, d time.Duration) error {
+	timer := time.NewTimer(d)
+	defer timer.Stop()
+	select {
+	case <-ctx.Done():
+		return ctx.Err()
+	case <-timer.C:
+		return nil
+	}
+}
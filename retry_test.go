@@ -0,0 +1,150 @@
+package cryptopay
+
+import (
+	"context"
+	"errors"
+	"net/http"
+	"net/http/httptest"
+	"sync/atomic"
+	"testing"
+	"time"
+)
+
+// flakyServer returns server that responds with status code for first failures requests.
+func flakyServer(t *testing.T, failures int32, code int) (*httptest.Server, *int32) {
+	var calls int32
+	s := httptest.NewServer(http.HandlerFunc(func(rw http.ResponseWriter, r *http.Request) {
+		if atomic.AddInt32(&calls, 1) <= failures {
+			rw.WriteHeader(code)
+			rw.Write([]byte("<html>Bad Gateway</html>"))
+			return
+		}
+		writeJson(rw, 200, JSON{
+			"ok": true,
+			"result": JSON{
+				"app_id": 1,
+				"name":   "flaky",
+			},
+		})
+	}))
+	t.Cleanup(s.Close)
+	return s, &calls
+}
+
+func getRetryApi(s *httptest.Server, policy RetryPolicy) *ApiCore {
+	api := NewApi("1:test_token", s.URL, s.Client())
+	api.SetRetryPolicy(policy)
+	return api
+}
+
+func TestRetryPolicy_delay(t *testing.T) {
+	p := RetryPolicy{BaseDelay: 10 * time.Millisecond, MaxDelay: 50 * time.Millisecond}
+	var cases = []struct {
+		retry    int
+		expected time.Duration
+	}{
+		{retry: 1, expected: 10 * time.Millisecond},
+		{retry: 2, expected: 20 * time.Millisecond},
+		{retry: 3, expected: 40 * time.Millisecond},
+		{retry: 4, expected: 50 * time.Millisecond},
+		{retry: 100, expected: 50 * time.Millisecond},
+	}
+	for _, tc := range cases {
+		if got := p.delay(tc.retry); got != tc.expected {
+			t.Errorf("delay(%d) = %s, expected %s", tc.retry, got, tc.expected)
+		}
+	}
+	p.Jitter = 0.5
+	for i := 0; i < 100; i++ {
+		if got := p.delay(2); got < 10*time.Millisecond || got > 20*time.Millisecond {
+			t.Fatalf("delay with jitter %s out of range", got)
+		}
+	}
+}
+
+func TestApiCore_Retry(t *testing.T) {
+	policy := RetryPolicy{MaxAttempts: 3, BaseDelay: time.Millisecond}
+	t.Run("recovered", func(t *testing.T) {
+		s, calls := flakyServer(t, 2, http.StatusBadGateway)
+		me, err := getRetryApi(s, policy).GetMe()
+		if err != nil {
+			t.Fatal(err)
+		}
+		if !me.IsSuccessfully() || atomic.LoadInt32(calls) != 3 {
+			t.Errorf("ok(%v), calls(%d) != 3", me.Ok, atomic.LoadInt32(calls))
+		}
+	})
+	t.Run("exhausted", func(t *testing.T) {
+		s, calls := flakyServer(t, 5, http.StatusServiceUnavailable)
+		if _, err := getRetryApi(s, policy).GetMe(); err == nil {
+			t.Error("err == nil")
+		}
+		if atomic.LoadInt32(calls) != 3 {
+			t.Errorf("calls(%d) != 3", atomic.LoadInt32(calls))
+		}
+	})
+	t.Run("not retryable status", func(t *testing.T) {
+		s, calls := flakyServer(t, 1, http.StatusNotImplemented)
+		getRetryApi(s, policy).GetMe()
+		if atomic.LoadInt32(calls) != 1 {
+			t.Errorf("calls(%d) != 1", atomic.LoadInt32(calls))
+		}
+	})
+	t.Run("non-idempotent", func(t *testing.T) {
+		s, calls := flakyServer(t, 1, http.StatusBadGateway)
+		getRetryApi(s, policy).CreateInvoice(CreateInvoiceOptions{Asset: TON, Amount: NewAmount(1, 0)})
+		if atomic.LoadInt32(calls) != 1 {
+			t.Errorf("createInvoice retried, calls(%d) != 1", atomic.LoadInt32(calls))
+		}
+
+		s, calls = flakyServer(t, 1, http.StatusBadGateway)
+		optIn := policy
+		optIn.RetryNonIdempotent = true
+		getRetryApi(s, optIn).CreateInvoice(CreateInvoiceOptions{Asset: TON, Amount: NewAmount(1, 0)})
+		if atomic.LoadInt32(calls) != 2 {
+			t.Errorf("createInvoice not retried with opt-in, calls(%d) != 2", atomic.LoadInt32(calls))
+		}
+	})
+	t.Run("transfer", func(t *testing.T) {
+		s, calls := flakyServer(t, 1, http.StatusBadGateway)
+		getRetryApi(s, policy).DoTransfer(DoTransferOptions{SpendId: "retry"})
+		if atomic.LoadInt32(calls) != 2 {
+			t.Errorf("transfer not retried, calls(%d) != 2", atomic.LoadInt32(calls))
+		}
+
+		s, calls = flakyServer(t, 1, http.StatusBadGateway)
+		getRetryApi(s, policy).DoTransfer(DoTransferOptions{UserId: 1, Asset: TON, Amount: NewAmount(1, 0)})
+		if atomic.LoadInt32(calls) != 1 {
+			t.Errorf("transfer without spend_id retried on 5xx, calls(%d) != 1", atomic.LoadInt32(calls))
+		}
+	})
+	t.Run("transfer timeout", func(t *testing.T) {
+		var calls int32
+		s := httptest.NewServer(http.HandlerFunc(func(rw http.ResponseWriter, r *http.Request) {
+			atomic.AddInt32(&calls, 1)
+			time.Sleep(50 * time.Millisecond)
+		}))
+		defer s.Close()
+		client := s.Client()
+		client.Timeout = 10 * time.Millisecond
+		api := NewApi("1:test_token", s.URL, client)
+		alwaysRetry := policy
+		alwaysRetry.RetryableError = func(error) bool { return true }
+		api.SetRetryPolicy(alwaysRetry)
+		if _, err := api.DoTransfer(DoTransferOptions{UserId: 1, Asset: TON, Amount: NewAmount(1, 0)}); err == nil {
+			t.Error("err == nil")
+		}
+		if atomic.LoadInt32(&calls) != 1 {
+			t.Errorf("transfer without spend_id retried on timeout, calls(%d) != 1", atomic.LoadInt32(&calls))
+		}
+	})
+	t.Run("context canceled during backoff", func(t *testing.T) {
+		s, _ := flakyServer(t, 5, http.StatusBadGateway)
+		ctx, cancel := context.WithTimeout(context.Background(), 20*time.Millisecond)
+		defer cancel()
+		slow := RetryPolicy{MaxAttempts: 3, BaseDelay: time.Minute, MaxDelay: time.Minute}
+		if _, err := getRetryApi(s, slow).GetMeCtx(ctx); !errors.Is(err, context.DeadlineExceeded) {
+			t.Errorf("err(%v) != context.DeadlineExceeded", err)
+		}
+	})
+}
@@ -0,0 +1,236 @@
+package cryptopay
+
+import (
+	"errors"
+	"fmt"
+	"net/url"
+	"strings"
+	"unicode/utf8"
+)
+
+// Limits of API params.
+const (
+	maxExpiresIn         = 2678400
+	maxDescriptionLength = 1024
+	maxHiddenMsgLength   = 2048
+	maxPayloadSize       = 4096
+	maxCommentLength     = 1024
+	maxSpendIdLength     = 64
+	maxPageCount         = 1000
+)
+
+// FieldError describes invalid field of options.
+type FieldError struct {
+	Field   string // Name of field in options struct.
+	Message string // Description of problem.
+	Err     error  // Sentinel error of problem, may be nil. See ValidationError.Is.
+}
+
+func (e FieldError) Error() string {
+	return e.Field + ": " + e.Message
+}
+
+// ValidationError is returned by Validate methods of options. It contains all invalid fields at once.
+type ValidationError struct {
+	Errors []FieldError
+}
+
+// GetValidationError retrieves the ValidationError from given error. If unsuccessfully returns nil.
+func GetValidationError(err error) *ValidationError {
+	var validationErr *ValidationError
+	if errors.As(err, &validationErr) {
+		return validationErr
+	}
+	return nil
+}
+
+func (e ValidationError) Error() string {
+	messages := make([]string, len(e.Errors))
+	for i, fieldErr := range e.Errors {
+		messages[i] = fieldErr.Error()
+	}
+	return "crypto-pay/api: invalid options: " + strings.Join(messages, "; ")
+}
+
+// Is reports whether sentinel error of any invalid field matches target.
+// For example, errors.Is(err, ErrorCurrencyMismatch) for error of CreateInvoiceOptions.Validate.
+func (e ValidationError) Is(target error) bool {
+	for _, fieldErr := range e.Errors {
+		if fieldErr.Err != nil && errors.Is(fieldErr.Err, target) {
+			return true
+		}
+	}
+	return false
+}
+
+// Has reports whether field is invalid.
+func (e ValidationError) Has(field string) bool {
+	for _, fieldErr := range e.Errors {
+		if fieldErr.Field == field {
+			return true
+		}
+	}
+	return false
+}
+
+// validator collects errors of fields.
+type validator struct {
+	errors []FieldError
+}
+
+func (v *validator) add(field, format string, args ...interface{}) {
+	v.errors = append(v.errors, FieldError{Field: field, Message: fmt.Sprintf(format, args...)})
+}
+
+// sentinel adds error of field that matches sentinel error err.
+func (v *validator) sentinel(field string, err error, format string, args ...interface{}) {
+	v.errors = append(v.errors, FieldError{Field: field, Message: fmt.Sprintf(format, args...), Err: err})
+}
+
+// maxLength checks that count of characters in value is not greater than max.
+func (v *validator) maxLength(field, value string, max int) {
+	if utf8.RuneCountInString(value) > max {
+		v.add(field, "must be up to %d characters", max)
+	}
+}
+
+// positive checks that amount is required and greater than zero.
+func (v *validator) positive(field string, amount Amount) {
+	if amount.Sign() <= 0 {
+		v.add(field, "must be greater than zero")
+	}
+}
+
+// page checks offset and count of paginated methods.
+func (v *validator) page(offset, count int) {
+	if offset < 0 {
+		v.add("Offset", "must not be negative")
+	}
+	if count < 0 || count > maxPageCount {
+		v.add("Count", "must be between 1 and %d", maxPageCount)
+	}
+}
+
+// httpUrl checks that value is absolute http or https URL.
+func (v *validator) httpUrl(field, value string) {
+	u, err := url.Parse(value)
+	if err != nil || (u.Scheme != "http" && u.Scheme != "https") || u.Host == "" {
+		v.add(field, "must be http or https URL")
+	}
+}
+
+// err returns ValidationError if there are invalid fields, otherwise nil.
+func (v *validator) err() error {
+	if len(v.errors) == 0 {
+		return nil
+	}
+	return &ValidationError{Errors: v.errors}
+}
+
+// Validate checks options against limits of API without request. Returns *ValidationError.
+//
+// Mixing of crypto and fiat fields matches ErrorCurrencyMismatch by errors.Is.
+func (opt CreateInvoiceOptions) Validate() error {
+	var v validator
+	switch opt.CurrencyType {
+	case "", CurrencyCrypto:
+		if opt.Asset == "" {
+			v.add("Asset", "is required")
+		}
+		if opt.Fiat != "" {
+			v.sentinel("Fiat", ErrorCurrencyMismatch, "must be empty for crypto invoice")
+		}
+		if len(opt.AcceptedAssets) != 0 {
+			v.sentinel("AcceptedAssets", ErrorCurrencyMismatch, "must be empty for crypto invoice")
+		}
+	case CurrencyFiat:
+		if opt.Asset != "" {
+			v.sentinel("Asset", ErrorCurrencyMismatch, "must be empty for fiat invoice")
+		}
+		if opt.Fiat == "" {
+			v.add("Fiat", "is required for fiat invoice")
+		}
+	default:
+		v.add("CurrencyType", "unknown currency type %q", opt.CurrencyType)
+	}
+	v.positive("Amount", opt.Amount)
+	v.maxLength("Description", opt.Description, maxDescriptionLength)
+	v.maxLength("HiddenMessage", opt.HiddenMessage, maxHiddenMsgLength)
+	if opt.PaidButtonName != "" && opt.PaidButtonUrl == "" {
+		v.add("PaidButtonUrl", "is required if PaidButtonName is set")
+	}
+	if opt.PaidButtonUrl != "" {
+		v.httpUrl("PaidButtonUrl", opt.PaidButtonUrl)
+	}
+	if len(opt.Payload) > maxPayloadSize {
+		v.add("Payload", "must be up to %d bytes", maxPayloadSize)
+	}
+	if opt.ExpiresIn < 0 || opt.ExpiresIn > maxExpiresIn {
+		v.add("ExpiresIn", "must be between 1 and %d", maxExpiresIn)
+	}
+	return v.err()
+}
+
+// Validate checks options against limits of API without request. Returns *ValidationError.
+func (opt DoTransferOptions) Validate() error {
+	var v validator
+	if opt.UserId == 0 {
+		v.add("UserId", "is required")
+	}
+	if opt.Asset == "" {
+		v.add("Asset", "is required")
+	}
+	v.positive("Amount", opt.Amount)
+	if opt.SpendId == "" {
+		v.add("SpendId", "is required")
+	}
+	v.maxLength("SpendId", opt.SpendId, maxSpendIdLength)
+	v.maxLength("Comment", opt.Comment, maxCommentLength)
+	return v.err()
+}
+
+// Validate checks options against limits of API without request. Returns *ValidationError.
+func (opt GetInvoicesOptions) Validate() error {
+	var v validator
+	v.page(opt.Offset, opt.Count)
+	return v.err()
+}
+
+// Validate checks options against limits of API without request. Returns *ValidationError.
+func (opt GetTransfersOptions) Validate() error {
+	var v validator
+	v.maxLength("SpendId", opt.SpendId, maxSpendIdLength)
+	v.page(opt.Offset, opt.Count)
+	return v.err()
+}
+
+// Validate checks options against limits of API without request. Returns *ValidationError.
+//
+// Pin both to user id and username matches ErrorCheckPinConflict by errors.Is.
+func (opt CreateCheckOptions) Validate() error {
+	var v validator
+	if opt.Asset == "" {
+		v.add("Asset", "is required")
+	}
+	if opt.PinToUserId != 0 && opt.PinToUsername != "" {
+		v.sentinel("PinToUsername", ErrorCheckPinConflict, "must be empty if PinToUserId is set")
+	}
+	v.positive("Amount", opt.Amount)
+	return v.err()
+}
+
+// Validate checks options against limits of API without request. Returns *ValidationError.
+func (opt GetChecksOptions) Validate() error {
+	var v validator
+	v.page(opt.Offset, opt.Count)
+	return v.err()
+}
+
+// Validate checks options against limits of API without request. Returns *ValidationError.
+func (opt GetStatsOptions) Validate() error {
+	var v validator
+	if !opt.StartAt.IsZero() && !opt.EndAt.IsZero() && opt.EndAt.Before(opt.StartAt) {
+		v.add("EndAt", "must not be before StartAt")
+	}
+	return v.err()
+}
@@ -0,0 +1,174 @@
+package cryptopay
+
+import (
+	"errors"
+	"strings"
+	"testing"
+	"time"
+)
+
+func TestOptions_Validate(t *testing.T) {
+	validInvoice := CreateInvoiceOptions{Asset: TON, Amount: NewAmount(1, 0)}
+	validTransfer := DoTransferOptions{UserId: 1, Asset: TON, Amount: NewAmount(1, 0), SpendId: "1"}
+	var cases = []struct {
+		name    string
+		opt     interface{ Validate() error }
+		invalid []string
+	}{
+		{name: "invoice valid", opt: validInvoice},
+		{
+			name: "invoice fiat valid",
+			opt:  CreateInvoiceOptions{CurrencyType: CurrencyFiat, Fiat: USD, Amount: NewAmount(1, 0)},
+		},
+		{
+			name: "invoice button valid",
+			opt: CreateInvoiceOptions{
+				Asset:          TON,
+				Amount:         NewAmount(1, 0),
+				PaidButtonName: ButtonOpenBot,
+				PaidButtonUrl:  "https://t.me/CryptoBot",
+				ExpiresIn:      maxExpiresIn,
+			},
+		},
+		{
+			name:    "invoice empty",
+			opt:     CreateInvoiceOptions{},
+			invalid: []string{"Asset", "Amount"},
+		},
+		{
+			name: "invoice limits",
+			opt: CreateInvoiceOptions{
+				Asset:         TON,
+				Amount:        NewAmount(-1, 0),
+				Description:   strings.Repeat("d", maxDescriptionLength+1),
+				HiddenMessage: strings.Repeat("h", maxHiddenMsgLength+1),
+				Payload:       strings.Repeat("p", maxPayloadSize+1),
+				ExpiresIn:     maxExpiresIn + 1,
+			},
+			invalid: []string{"Amount", "Description", "HiddenMessage", "Payload", "ExpiresIn"},
+		},
+		{
+			name: "invoice unicode description",
+			opt: CreateInvoiceOptions{
+				Asset:       TON,
+				Amount:      NewAmount(1, 0),
+				Description: strings.Repeat("й", maxDescriptionLength),
+			},
+		},
+		{
+			name: "invoice button without url",
+			opt: CreateInvoiceOptions{
+				Asset:          TON,
+				Amount:         NewAmount(1, 0),
+				PaidButtonName: ButtonCallback,
+			},
+			invalid: []string{"PaidButtonUrl"},
+		},
+		{
+			name: "invoice ftp url",
+			opt: CreateInvoiceOptions{
+				Asset:          TON,
+				Amount:         NewAmount(1, 0),
+				PaidButtonName: ButtonCallback,
+				PaidButtonUrl:  "ftp://example.com",
+			},
+			invalid: []string{"PaidButtonUrl"},
+		},
+		{
+			name:    "invoice fiat in crypto",
+			opt:     CreateInvoiceOptions{Asset: TON, Amount: NewAmount(1, 0), Fiat: USD, AcceptedAssets: []Asset{BTC}},
+			invalid: []string{"Fiat", "AcceptedAssets"},
+		},
+		{
+			name:    "invoice asset in fiat",
+			opt:     CreateInvoiceOptions{CurrencyType: CurrencyFiat, Fiat: USD, Asset: TON, Amount: NewAmount(1, 0)},
+			invalid: []string{"Asset"},
+		},
+		{
+			name:    "invoice fiat without code",
+			opt:     CreateInvoiceOptions{CurrencyType: CurrencyFiat, Amount: NewAmount(1, 0)},
+			invalid: []string{"Fiat"},
+		},
+		{
+			name:    "invoice unknown currency type",
+			opt:     CreateInvoiceOptions{CurrencyType: "barter", Amount: NewAmount(1, 0)},
+			invalid: []string{"CurrencyType"},
+		},
+		{name: "transfer valid", opt: validTransfer},
+		{
+			name:    "transfer empty",
+			opt:     DoTransferOptions{},
+			invalid: []string{"UserId", "Asset", "Amount", "SpendId"},
+		},
+		{
+			name: "transfer limits",
+			opt: DoTransferOptions{
+				UserId:  1,
+				Asset:   TON,
+				Amount:  NewAmount(1, 0),
+				SpendId: strings.Repeat("s", maxSpendIdLength+1),
+				Comment: strings.Repeat("c", maxCommentLength+1),
+			},
+			invalid: []string{"SpendId", "Comment"},
+		},
+		{name: "invoices valid", opt: GetInvoicesOptions{Count: maxPageCount}},
+		{
+			name:    "invoices page",
+			opt:     GetInvoicesOptions{Offset: -1, Count: maxPageCount + 1},
+			invalid: []string{"Offset", "Count"},
+		},
+		{name: "transfers page", opt: GetTransfersOptions{Count: -1}, invalid: []string{"Count"}},
+		{name: "checks page", opt: GetChecksOptions{Offset: -5}, invalid: []string{"Offset"}},
+		{name: "check empty", opt: CreateCheckOptions{}, invalid: []string{"Asset", "Amount"}},
+		{
+			name:    "check pin conflict",
+			opt:     CreateCheckOptions{Asset: TON, Amount: NewAmount(1, 0), PinToUserId: 1, PinToUsername: "user"},
+			invalid: []string{"PinToUsername"},
+		},
+		{
+			name: "stats reversed",
+			opt: GetStatsOptions{
+				StartAt: time.Date(2022, 5, 2, 0, 0, 0, 0, time.UTC),
+				EndAt:   time.Date(2022, 5, 1, 0, 0, 0, 0, time.UTC),
+			},
+			invalid: []string{"EndAt"},
+		},
+	}
+	for _, tc := range cases {
+		t.Run(tc.name, func(t *testing.T) {
+			err := tc.opt.Validate()
+			if len(tc.invalid) == 0 {
+				if err != nil {
+					t.Fatalf("unexpected error %v", err)
+				}
+				return
+			}
+			validationErr := GetValidationError(err)
+			if validationErr == nil {
+				t.Fatalf("err(%v) is not ValidationError", err)
+			}
+			if len(validationErr.Errors) != len(tc.invalid) {
+				t.Errorf("expected %d errors, but got: %v", len(tc.invalid), err)
+			}
+			for _, field := range tc.invalid {
+				if !validationErr.Has(field) {
+					t.Errorf("field %s is not reported: %v", field, err)
+				}
+			}
+		})
+	}
+}
+
+func TestValidationError_Is(t *testing.T) {
+	err := CreateInvoiceOptions{CurrencyType: CurrencyFiat, Fiat: USD, Asset: TON, Amount: NewAmount(1, 0)}.Validate()
+	if !errors.Is(err, ErrorCurrencyMismatch) || errors.Is(err, ErrorCheckPinConflict) {
+		t.Errorf("err(%v) doesn't match only ErrorCurrencyMismatch", err)
+	}
+	err = CreateCheckOptions{Asset: TON, Amount: NewAmount(1, 0), PinToUserId: 1, PinToUsername: "user"}.Validate()
+	if !errors.Is(err, ErrorCheckPinConflict) {
+		t.Errorf("err(%v) != ErrorCheckPinConflict", err)
+	}
+	if err := (CreateInvoiceOptions{}).Validate(); errors.Is(err, ErrorCurrencyMismatch) {
+		t.Errorf("err(%v) matches ErrorCurrencyMismatch", err)
+	}
+}
@@ -1,21 +1,33 @@
 package cryptopay
 
 import (
+	"bytes"
+	"context"
 	"crypto/hmac"
 	"crypto/sha256"
 	"encoding/hex"
 	"encoding/json"
+	"errors"
 	"fmt"
 	"io"
 	"net/http"
+	"sync"
+	"sync/atomic"
 	"time"
 )
 
 type (
-	// Handler is signature of handler for Webhook
+	// Handler is signature of handler for Webhook. It's called asynchronously after response to API.
 	Handler func(update *WebhookUpdate)
+	// HandlerFunc is signature of synchronous handler for Webhook. It's called before response to API,
+	// so returned error makes API redeliver the update. Use HandlerError for control of response status code.
+	HandlerFunc func(ctx context.Context, update *WebhookUpdate) error
 	// ErrorHandler is signature of Webhook.OnError
 	ErrorHandler func(r *http.Request, err error)
+	// PanicHandler is signature of Webhook.OnPanic
+	PanicHandler func(r *http.Request, err *PanicError)
+	// HandlerID identifies handler bound to Webhook. Unlike index, it doesn't change after deletion of other handlers.
+	HandlerID uint64
 )
 
 // UpdateType is type of webhook update.
@@ -29,6 +41,8 @@ const (
 const (
 	headerSignatureName = "crypto-pay-api-signature"
 	wrongSignature      = "wrong request signature"
+	staleUpdate         = "stale update"
+	replayedUpdate      = "replayed update"
 )
 
 // ErrorWrongSignature is returned if webhook don't verify update.
@@ -38,6 +52,23 @@ const (
 // If this happens, the update is not processed, but Webhook.OnError is called
 var ErrorWrongSignature = fmt.Errorf("crypto-pay/webhook: %s", wrongSignature)
 
+// ErrorStaleUpdate is passed to Webhook.OnError if RequestDate of update is out of freshness window
+// (see Webhook.MaxUpdateAge). It may be replay of captured request.
+var ErrorStaleUpdate = fmt.Errorf("crypto-pay/webhook: %s", staleUpdate)
+
+// ErrorReplayedUpdate is passed to Webhook.OnError if request with same signature was already accepted
+// (see Webhook.Nonces).
+var ErrorReplayedUpdate = fmt.Errorf("crypto-pay/webhook: %s", replayedUpdate)
+
+// ErrorUpdateInProgress is passed to Webhook.OnError if update is redelivered while handlers still process
+// its previous delivery (see Webhook.Dedup). Webhook responds with 503 status code, so API redelivers it later,
+// when result of processing is known.
+var ErrorUpdateInProgress = errors.New("crypto-pay/webhook: update is still in progress")
+
+// ErrorShutdown is passed to Webhook.OnError if update is received after Webhook.Shutdown.
+// Webhook responds with 503 status code, so API redelivers the update later.
+var ErrorShutdown = errors.New("crypto-pay/webhook: webhook is shut down")
+
 // WebhookUpdate is object of update from request body.
 type WebhookUpdate struct {
 	// Id is Non-unique update ID.
@@ -50,12 +81,79 @@ type WebhookUpdate struct {
 	Payload Invoice `json:"payload"`
 }
 
+// HandlerError is error of HandlerFunc with status code of webhook response.
+// Other errors of handlers produce response with 500 status code.
+type HandlerError struct {
+	StatusCode int   // HTTP status code of response, must be non-2xx.
+	Err        error // Original error.
+}
+
+func (e HandlerError) Error() string {
+	return fmt.Sprintf("crypto-pay/webhook: handler failed with status %d: %v", e.StatusCode, e.Err)
+}
+
+func (e HandlerError) Unwrap() error { return e.Err }
+
+// boundHandler is handler with its ID. Only one of handler and fn is set.
+type boundHandler struct {
+	id      HandlerID
+	handler Handler
+	fn      HandlerFunc
+}
+
 //Webhook representation http.Handler for works with CryptoPay updates
+//
+// Handlers can be bound and deleted concurrently with processing of updates.
 type Webhook struct {
+	// mu protects handlers and lastId.
+	mu sync.RWMutex
 	// handlers set of split by update type
-	handlers map[UpdateType][]Handler
-	// OnError handler for errors
+	handlers map[UpdateType][]boundHandler
+	// lastId is last issued HandlerID.
+	lastId HandlerID
+	// middlewares wrap dispatch of update, first is outermost.
+	middlewares []Middleware
+	// OnError handler for errors. Set it before serving updates.
 	OnError ErrorHandler
+	// OnPanic handler for panics of asynchronous handlers, which happen after response.
+	// If it's nil, panics are passed to OnError. Panics of synchronous handlers and middlewares before response
+	// are returned as *PanicError, so webhook responds with 500 status code and calls OnError.
+	// Set it before serving updates.
+	OnPanic PanicHandler
+	// HandlerTimeout limits time of all HandlerFunc handlers for update. If it's exceeded,
+	// webhook responds with 503 status code without waiting for handlers. Zero means no limit.
+	// Set it before serving updates.
+	HandlerTimeout time.Duration
+	// Dedup is store of processed updates. If set, every logical event (see UpdateKey) reaches handlers
+	// only once, repeated deliveries are acknowledged without dispatch. Key is forgotten only when handlers
+	// really fail, even after timeout; delivery that comes while handlers are still running gets 503 status code
+	// with ErrorUpdateInProgress. Set it before serving updates.
+	Dedup DedupStore
+	// MaxUpdateAge is freshness window of updates. If it's set, updates with RequestDate older than
+	// MaxUpdateAge (or later than now) are rejected with ErrorStaleUpdate. Set it before serving updates.
+	MaxUpdateAge time.Duration
+	// ClockSkew is allowed difference between clocks of API and server, it widens freshness window in both sides.
+	ClockSkew time.Duration
+	// Nonces is store of signatures of accepted requests. If set, exact replays are rejected with ErrorReplayedUpdate.
+	// Keys should be kept at least for MaxUpdateAge + 2 * ClockSkew. Set it before serving updates.
+	Nonces DedupStore
+	// Dispatcher runs Handler handlers by pool of workers. If it's nil, every handler is called in new goroutine.
+	// If queue of dispatcher is full, webhook responds with 503 status code. Set it before serving updates.
+	Dispatcher *Dispatcher
+	// ownsDispatcher is set if Dispatcher is created by NewClient, then Shutdown closes it.
+	ownsDispatcher bool
+	// stateMu protects closing, inflight, idle and pending.
+	stateMu sync.Mutex
+	// closing is set by Shutdown, new updates are rejected.
+	closing bool
+	// inflight is count of updates which processing isn't finished, including asynchronous handlers.
+	inflight int
+	// idle is closed when inflight becomes zero after Shutdown.
+	idle chan struct{}
+	// pending is count of deliveries for dedup keys which chain of handlers isn't finished.
+	pending map[string]int
+	// now returns current time, it's replaced in tests.
+	now func() time.Time
 	// tokenHash is SHA256 hash of app's token.
 	// Webhook getting only hash because it minimizes calls hash functions and process time for verifyUpdate.
 	tokenHash []byte
@@ -63,41 +161,126 @@ type Webhook struct {
 
 // NewWebhook returns new Webhook.
 func NewWebhook(token string, defaultHandlers map[UpdateType][]Handler, onError ErrorHandler) *Webhook {
-	handlers := defaultHandlers
-	if handlers == nil {
-		handlers = make(map[UpdateType][]Handler)
+	w := &Webhook{handlers: make(map[UpdateType][]boundHandler), OnError: onError, tokenHash: hashToken(token)}
+	for updateType, handlers := range defaultHandlers {
+		for _, handler := range handlers {
+			w.Bind(updateType, handler)
+		}
 	}
-	hash := sha256.New()
-	hash.Write([]byte(token))
-	return &Webhook{handlers: handlers, OnError: onError, tokenHash: hash.Sum(nil)}
+	return w
 }
 
-// Bind add handler given update type. Returns handler index.
-func (w *Webhook) Bind(updateType UpdateType, handler Handler) int {
-	v, ok := w.handlers[updateType]
-	if !ok {
-		v = []Handler{handler}
-	} else {
-		v = append(v, handler)
+// Bind add handler given update type. Returns ID of handler for DeleteHandler.
+func (w *Webhook) Bind(updateType UpdateType, handler Handler) HandlerID {
+	w.mu.Lock()
+	defer w.mu.Unlock()
+	return w.bind(updateType, handler)
+}
+
+// Handle add synchronous handler given update type. Returns ID of handler for DeleteHandler.
+//
+// HandlerFunc handlers are called one by one before response to API. If one of them returns error,
+// others are not called and webhook responds with non-2xx status code, so API will redeliver the update
+// and all handlers will be called again.
+func (w *Webhook) Handle(updateType UpdateType, fn HandlerFunc) HandlerID {
+	w.mu.Lock()
+	defer w.mu.Unlock()
+	return w.bindHandler(updateType, boundHandler{fn: fn})
+}
+
+// Once add handler that will be called only for first update of given type.
+// After that handler is deleted. Returns ID of handler for DeleteHandler.
+func (w *Webhook) Once(updateType UpdateType, handler Handler) HandlerID {
+	w.mu.Lock()
+	defer w.mu.Unlock()
+	var once sync.Once
+	var id HandlerID
+	// id is assigned before handler can be called, because dispatch requires mu.
+	id = w.bind(updateType, func(update *WebhookUpdate) {
+		once.Do(func() {
+			w.DeleteHandler(id)
+			handler(update)
+		})
+	})
+	return id
+}
+
+// bind adds handler with new ID. Must be called with locked mu.
+func (w *Webhook) bind(updateType UpdateType, handler Handler) HandlerID {
+	return w.bindHandler(updateType, boundHandler{handler: handler})
+}
+
+// bindHandler adds handler with new ID. Must be called with locked mu.
+func (w *Webhook) bindHandler(updateType UpdateType, h boundHandler) HandlerID {
+	if w.handlers == nil {
+		w.handlers = make(map[UpdateType][]boundHandler)
+	}
+	w.lastId++
+	h.id = w.lastId
+	w.handlers[updateType] = append(w.handlers[updateType], h)
+	return h.id
+}
+
+// DeleteHandler deletes handler with given ID. Returns false if handler not found.
+func (w *Webhook) DeleteHandler(id HandlerID) bool {
+	w.mu.Lock()
+	defer w.mu.Unlock()
+	for updateType, handlers := range w.handlers {
+		for i, h := range handlers {
+			if h.id == id {
+				w.handlers[updateType] = append(handlers[:i:i], handlers[i+1:]...)
+				return true
+			}
+		}
 	}
-	w.handlers[updateType] = v
-	return len(w.handlers[updateType]) - 1
+	return false
 }
 
 // DeleteHandlers deletes all handlers given type. Also, can delete all handlers for all update types.
 // If you want to delete all handlers for all types, then pass "*" as a parameter
 func (w *Webhook) DeleteHandlers(updateType UpdateType) {
+	w.mu.Lock()
+	defer w.mu.Unlock()
 	if updateType == "*" {
-		w.handlers = make(map[UpdateType][]Handler)
+		w.handlers = make(map[UpdateType][]boundHandler)
 		return
 	}
 	delete(w.handlers, updateType)
 }
 
-// DeleteHandlerByIndex deletes handler given type and index.
+// DeleteHandlerByIndex deletes handler given type and index. Does nothing if index is out of range.
+//
+// Deprecated: indexes shift after deletion of handlers, use DeleteHandler with ID returned by Bind.
 func (w *Webhook) DeleteHandlerByIndex(updateType UpdateType, i int) {
+	w.mu.Lock()
+	defer w.mu.Unlock()
 	a := w.handlers[updateType]
-	w.handlers[updateType] = append(a[:i], a[i+1:]...)
+	if i < 0 || i >= len(a) {
+		return
+	}
+	w.handlers[updateType] = append(a[:i:i], a[i+1:]...)
+}
+
+// handlersFor returns copy of asynchronous handlers for given update type.
+func (w *Webhook) handlersFor(updateType UpdateType) []Handler {
+	handlers, _, _ := w.snapshot(updateType)
+	return handlers
+}
+
+// snapshot returns copies of asynchronous and synchronous handlers for given update type and middlewares.
+func (w *Webhook) snapshot(updateType UpdateType) ([]Handler, []HandlerFunc, []Middleware) {
+	w.mu.RLock()
+	defer w.mu.RUnlock()
+	var handlers []Handler
+	var funcs []HandlerFunc
+	for _, h := range w.handlers[updateType] {
+		if h.fn != nil {
+			funcs = append(funcs, h.fn)
+		} else {
+			handlers = append(handlers, h.handler)
+		}
+	}
+	return handlers, funcs, w.middlewares[:len(w.middlewares):len(w.middlewares)]
 }
 
 // ServeHTTP implementing http.Handler.
@@ -107,8 +290,20 @@ func (w *Webhook) DeleteHandlerByIndex(updateType UpdateType, i int) {
 //
 // If you use other router you can adapt. For this you must create handler that call this method.
 // Examples of adapt see in README.md file
-func (w Webhook) ServeHTTP(rw http.ResponseWriter, r *http.Request) {
+//
+// Middlewares wrap all handlers of update. HandlerFunc handlers are called first, then Handler handlers
+// are started (by Dispatcher if it's set) and webhook responds to API without waiting for them.
+// The chain of middlewares returns when Handler handlers are finished, so context of chain is done by then.
+// Error returned by the chain after response is passed to OnError (*PanicError to OnPanic).
+// If middleware doesn't call next handler, no handlers are called.
+func (w *Webhook) ServeHTTP(rw http.ResponseWriter, r *http.Request) {
 	defer r.Body.Close()
+	ref := w.acquire()
+	if ref == nil {
+		w.handlerError(rw, r, &HandlerError{StatusCode: http.StatusServiceUnavailable, Err: ErrorShutdown})
+		return
+	}
+	defer ref.release()
 	data, _ := io.ReadAll(r.Body)
 	signature, _ := hex.DecodeString(r.Header.Get(headerSignatureName))
 	if !w.verifyUpdate(data, signature) {
@@ -120,22 +315,391 @@ func (w Webhook) ServeHTTP(rw http.ResponseWriter, r *http.Request) {
 		w.badRequestError(rw, r, err, "")
 		return
 	}
+	if err := w.checkFreshness(update); err != nil {
+		w.badRequestError(rw, r, err, staleUpdate)
+		return
+	}
+	var nonceKey string
+	if w.Nonces != nil {
+		nonceKey = "signature:" + hex.EncodeToString(signature)
+		first, err := w.Nonces.MarkSeen(nonceKey)
+		if err != nil {
+			w.handlerError(rw, r, err)
+			return
+		}
+		if !first {
+			w.badRequestError(rw, r, ErrorReplayedUpdate, replayedUpdate)
+			return
+		}
+	}
+	var dedupKey string
+	if w.Dedup != nil {
+		dedupKey = UpdateKey(update)
+		// Key is pending before MarkSeen, so duplicate can't miss running delivery.
+		inProgress := w.pend(dedupKey)
+		first, err := w.Dedup.MarkSeen(dedupKey)
+		if err != nil {
+			w.unpend(dedupKey)
+			w.forget(r, w.Nonces, nonceKey)
+			w.handlerError(rw, r, err)
+			return
+		}
+		if !first {
+			w.unpend(dedupKey)
+			if inProgress {
+				// Result of running delivery is unknown yet, it can fail and forget the key.
+				w.handlerError(rw, r, &HandlerError{StatusCode: http.StatusServiceUnavailable, Err: ErrorUpdateInProgress})
+				return
+			}
+			rw.WriteHeader(http.StatusOK)
+			return
+		}
+	}
+	handlers, funcs, middlewares := w.snapshot(update.UpdateType)
+	// accepted is closed when update passed middlewares and synchronous handlers
+	// and asynchronous handlers are started, so webhook can respond.
+	accepted := make(chan struct{})
+	var chain HandlerFunc = func(ctx context.Context, update *WebhookUpdate) error {
+		for _, fn := range funcs {
+			if err := fn(ctx, update); err != nil {
+				return err
+			}
+		}
+		return w.runHandlers(r, update, handlers, accepted)
+	}
+	for i := len(middlewares) - 1; i >= 0; i-- {
+		chain = middlewares[i](chain)
+	}
+	chain = Recover()(chain)
+	ctx := context.WithValue(r.Context(), requestContextKey{}, r)
+	// finish is called when chain really returns, even after timeout of response.
+	finish := func(err error, responded bool) {
+		if err != nil && !isClosed(accepted) {
+			// Update must be processed again on redelivery.
+			w.forget(r, w.Dedup, dedupKey)
+			w.forget(r, w.Nonces, nonceKey)
+		}
+		if err != nil && responded {
+			w.reportError(r, err)
+		}
+		if w.Dedup != nil {
+			w.unpend(dedupKey)
+		}
+	}
+	trivial := len(funcs)+len(middlewares)+len(handlers) == 0
+	if err := w.run(ctx, ref, chain, update, accepted, finish, trivial); err != nil {
+		w.handlerError(rw, r, err)
+		return
+	}
 	rw.WriteHeader(http.StatusOK)
-	if v, ok := w.handlers[update.UpdateType]; ok {
-		for _, handler := range v {
-			go handler(update)
+}
+
+// pend registers delivery of update with dedup key. Returns true if other delivery of the key is running.
+func (w *Webhook) pend(key string) bool {
+	w.stateMu.Lock()
+	defer w.stateMu.Unlock()
+	if w.pending == nil {
+		w.pending = make(map[string]int)
+	}
+	w.pending[key]++
+	return w.pending[key] > 1
+}
+
+// unpend removes delivery of update with dedup key.
+func (w *Webhook) unpend(key string) {
+	w.stateMu.Lock()
+	defer w.stateMu.Unlock()
+	if w.pending[key]--; w.pending[key] <= 0 {
+		delete(w.pending, key)
+	}
+}
+
+// runHandlers starts asynchronous handlers of update, closes accepted and waits until handlers are finished.
+// Returns ErrorQueueFull if Dispatcher doesn't accept update, or first panic of handlers as *PanicError.
+// Other panics are reported by reportError.
+func (w *Webhook) runHandlers(r *http.Request, update *WebhookUpdate, handlers []Handler, accepted chan struct{}) error {
+	if len(handlers) == 0 {
+		close(accepted)
+		return nil
+	}
+	var (
+		mu       sync.Mutex
+		panicErr error
+		wg       sync.WaitGroup
+	)
+	// Panic of one handler must not crash the program or break other handlers.
+	for i, handler := range handlers {
+		handler := handler
+		handlers[i] = func(update *WebhookUpdate) {
+			err := recoverPanic(update, handler)
+			if err == nil {
+				return
+			}
+			mu.Lock()
+			first := panicErr == nil
+			if first {
+				panicErr = err
+			}
+			mu.Unlock()
+			if !first {
+				w.reportError(r, err)
+			}
+		}
+	}
+	if w.Dispatcher != nil {
+		wg.Add(1)
+		if !w.Dispatcher.dispatch(dispatchJob{update: update, handlers: handlers, done: wg.Done}) {
+			return &HandlerError{StatusCode: http.StatusServiceUnavailable, Err: ErrorQueueFull}
+		}
+	} else {
+		wg.Add(len(handlers))
+		for _, handler := range handlers {
+			go func(handler Handler) {
+				defer wg.Done()
+				handler(update)
+			}(handler)
 		}
 	}
+	close(accepted)
+	wg.Wait()
+	return panicErr
+}
+
+// reportError passes error of handlers, which is returned after response, to OnPanic or OnError.
+func (w *Webhook) reportError(r *http.Request, err error) {
+	var panicErr *PanicError
+	if errors.As(err, &panicErr) && w.OnPanic != nil {
+		w.OnPanic(r, panicErr)
+	} else if w.OnError != nil {
+		w.OnError(r, err)
+	}
+}
+
+// Shutdown stops accepting of updates and waits until processing of accepted updates is finished,
+// including asynchronous handlers. New updates are rejected with 503 status code and ErrorShutdown,
+// so API redelivers them later.
+//
+// If ctx is done before, Shutdown returns count of updates which processing is abandoned and error of ctx.
+// After that Shutdown closes Dispatcher created by NewClient (Workers setting). Dispatcher set by caller
+// isn't closed, caller should close it after Shutdown.
+func (w *Webhook) Shutdown(ctx context.Context) (int, error) {
+	w.stateMu.Lock()
+	w.closing = true
+	if w.inflight == 0 {
+		w.stateMu.Unlock()
+		w.closeDispatcher()
+		return 0, nil
+	}
+	if w.idle == nil {
+		w.idle = make(chan struct{})
+	}
+	idle := w.idle
+	w.stateMu.Unlock()
+
+	select {
+	case <-idle:
+		w.closeDispatcher()
+		return 0, nil
+	case <-ctx.Done():
+		w.stateMu.Lock()
+		defer w.stateMu.Unlock()
+		return w.inflight, ctx.Err()
+	}
+}
+
+// closeDispatcher closes Dispatcher if it's owned by webhook. All updates must be processed.
+func (w *Webhook) closeDispatcher() {
+	if w.ownsDispatcher && w.Dispatcher != nil {
+		w.Dispatcher.Close()
+	}
+}
+
+// updateRef counts holders of update in processing: request and chain of handlers, which waits for asynchronous handlers.
+// Update is counted by Shutdown once, until the last holder releases it.
+type updateRef struct {
+	w    *Webhook
+	refs int32
+}
+
+// retain adds holder of update.
+func (u *updateRef) retain() {
+	atomic.AddInt32(&u.refs, 1)
+}
+
+// release removes holder of update. Processing of update is finished when the last holder is removed.
+func (u *updateRef) release() {
+	if atomic.AddInt32(&u.refs, -1) == 0 {
+		u.w.release()
+	}
+}
+
+// acquire registers update in processing and returns its reference held by caller. Returns nil after Shutdown.
+func (w *Webhook) acquire() *updateRef {
+	w.stateMu.Lock()
+	defer w.stateMu.Unlock()
+	if w.closing {
+		return nil
+	}
+	w.inflight++
+	return &updateRef{w: w, refs: 1}
+}
+
+// release marks processing of update as finished.
+func (w *Webhook) release() {
+	w.stateMu.Lock()
+	defer w.stateMu.Unlock()
+	w.inflight--
+	if w.inflight == 0 && w.idle != nil {
+		close(w.idle)
+		w.idle = nil
+	}
+}
+
+// run calls chain of handlers with timeout and returns when update is accepted, chain failed or timeout is exceeded.
+// Chain keeps running after that. When chain returns, finish is called with its error and whether
+// response is already sent. If chain is trivial, it's called without timeout.
+func (w *Webhook) run(ctx context.Context, ref *updateRef, chain HandlerFunc, update *WebhookUpdate,
+	accepted chan struct{}, finish func(err error, responded bool), trivial bool) error {
+	if trivial {
+		err := chain(ctx, update)
+		finish(err, false)
+		return err
+	}
+	if w.HandlerTimeout > 0 {
+		var cancel context.CancelFunc
+		ctx, cancel = context.WithTimeout(ctx, w.HandlerTimeout)
+		defer cancel()
+	}
+	done := make(chan error, 1)
+	// timedOut is closed if run returns before chain because of ctx.
+	timedOut := make(chan struct{})
+	// Chain keeps running after timeout, Shutdown must wait for it.
+	ref.retain()
+	go func() {
+		defer ref.release()
+		err := chain(ctx, update)
+		finish(err, isClosed(accepted) || isClosed(timedOut))
+		done <- err
+	}()
+	select {
+	case <-accepted:
+		return nil
+	case err := <-done:
+		if isClosed(accepted) {
+			return nil
+		}
+		return err
+	case <-ctx.Done():
+		if isClosed(accepted) {
+			return nil
+		}
+		close(timedOut)
+		return &HandlerError{StatusCode: http.StatusServiceUnavailable, Err: ctx.Err()}
+	}
+}
+
+// isClosed reports whether channel is closed.
+func isClosed(ch chan struct{}) bool {
+	select {
+	case <-ch:
+		return true
+	default:
+		return false
+	}
 }
 
 // verifyUpdate comparing HMAC-SHA-256 signature of request body with a secret key that is SHA256 hash of app's token and header parameter in requestSignature argument.
-func (w Webhook) verifyUpdate(requestBody, requestSignature []byte) bool {
-	mac := hmac.New(sha256.New, w.tokenHash)
-	mac.Write(requestBody)
-	return hmac.Equal(mac.Sum(nil), requestSignature)
+func (w *Webhook) verifyUpdate(requestBody, requestSignature []byte) bool {
+	return hmac.Equal(signBody(w.tokenHash, requestBody), requestSignature)
+}
+
+// SignWebhookBody returns signature of webhook request body for app with given token
+// in format of crypto-pay-api-signature header.
+//
+// It's useful for tests of handlers and replay of captured updates.
+func SignWebhookBody(token string, body []byte) string {
+	return hex.EncodeToString(signBody(hashToken(token), body))
+}
+
+// VerifyWebhookSignature reports whether signature from crypto-pay-api-signature header is valid for request body.
+func VerifyWebhookSignature(token string, body []byte, signature string) bool {
+	decoded, err := hex.DecodeString(signature)
+	if err != nil {
+		return false
+	}
+	return hmac.Equal(signBody(hashToken(token), body), decoded)
+}
+
+// NewSignedRequest returns POST request with update in body and valid signature, ready to feed to Webhook.ServeHTTP.
+// URL of request is "/", change it if request is sent to a server.
+func NewSignedRequest(token string, update *WebhookUpdate) (*http.Request, error) {
+	body, err := json.Marshal(update)
+	if err != nil {
+		return nil, err
+	}
+	req, err := http.NewRequest(http.MethodPost, "/", bytes.NewReader(body))
+	if err != nil {
+		return nil, err
+	}
+	req.Header.Set("Content-Type", "application/json")
+	req.Header.Set(headerSignatureName, SignWebhookBody(token, body))
+	return req, nil
+}
+
+// hashToken returns SHA256 hash of app's token, it's secret key for signatures.
+func hashToken(token string) []byte {
+	hash := sha256.Sum256([]byte(token))
+	return hash[:]
+}
+
+// signBody returns HMAC-SHA-256 of body with given key.
+func signBody(key, body []byte) []byte {
+	mac := hmac.New(sha256.New, key)
+	mac.Write(body)
+	return mac.Sum(nil)
+}
+
+// checkFreshness returns ErrorStaleUpdate if RequestDate of update is out of freshness window.
+func (w *Webhook) checkFreshness(update *WebhookUpdate) error {
+	if w.MaxUpdateAge <= 0 {
+		return nil
+	}
+	now := time.Now
+	if w.now != nil {
+		now = w.now
+	}
+	age := now().Sub(update.RequestDate)
+	if age > w.MaxUpdateAge+w.ClockSkew || age < -w.ClockSkew {
+		return fmt.Errorf("%w: request date %s", ErrorStaleUpdate, update.RequestDate.Format(time.RFC3339))
+	}
+	return nil
+}
+
+// forget removes key from store if store is set. Error is passed to OnError.
+func (w *Webhook) forget(r *http.Request, store DedupStore, key string) {
+	if store == nil {
+		return
+	}
+	if err := store.Forget(key); err != nil && w.OnError != nil {
+		w.OnError(r, err)
+	}
+}
+
+// handlerError responds with status code of error (500 by default) and calls OnError.
+func (w *Webhook) handlerError(rw http.ResponseWriter, r *http.Request, err error) {
+	code := http.StatusInternalServerError
+	var handlerErr *HandlerError
+	if errors.As(err, &handlerErr) && (handlerErr.StatusCode < 200 || handlerErr.StatusCode > 299) {
+		code = handlerErr.StatusCode
+	}
+	rw.WriteHeader(code)
+	rw.Write([]byte(http.StatusText(code)))
+	if w.OnError != nil {
+		w.OnError(r, err)
+	}
 }
 
-func (w Webhook) badRequestError(rw http.ResponseWriter, r *http.Request, err error, msg string) {
+func (w *Webhook) badRequestError(rw http.ResponseWriter, r *http.Request, err error, msg string) {
 	if msg != "" {
 		badRequest(rw, msg)
 	} else {
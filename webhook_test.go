@@ -2,16 +2,22 @@ package cryptopay
 
 import (
 	"bytes"
+	"context"
 	"crypto/hmac"
 	"crypto/sha256"
 	"encoding/hex"
 	"encoding/json"
+	"errors"
+	"fmt"
 	"hash"
 	"io"
 	"math/rand"
 	"net/http"
 	"net/http/httptest"
+	"reflect"
 	"strings"
+	"sync"
+	"sync/atomic"
 	"testing"
 	"time"
 )
@@ -30,14 +36,13 @@ func writeHmac(key, msg []byte) []byte {
 var tokenHash = writeHash(sha256.New(), "5675:test_token")
 
 func getWebhook(h map[UpdateType][]Handler, e ErrorHandler) *Webhook {
-	if h == nil {
-		h = map[UpdateType][]Handler{}
-	}
-	return &Webhook{
-		handlers:  h,
-		OnError:   e,
-		tokenHash: tokenHash,
+	w := &Webhook{OnError: e, tokenHash: tokenHash}
+	for updateType, handlers := range h {
+		for _, handler := range handlers {
+			w.Bind(updateType, handler)
+		}
 	}
+	return w
 }
 
 func TestWebhook_verifyUpdate(t *testing.T) {
@@ -51,7 +56,7 @@ func TestWebhook_verifyUpdate(t *testing.T) {
 			Status:          "paid",
 			Hash:            "someHash",
 			Asset:           BTC,
-			Amount:          "3.14",
+			Amount:          MustParseAmount("3.14"),
 			PayUrl:          "some.url/someHash",
 			CreatedAt:       time.Now().Add(-time.Minute),
 			AllowComments:   false,
@@ -89,11 +94,11 @@ func TestWebhook_Bind(t *testing.T) {
 	w.Bind("test", func(_ *WebhookUpdate) {
 		handlerResult = false
 	})
-	if w.handlers["test"][0](nil); !handlerResult {
+	if w.handlersFor("test")[0](nil); !handlerResult {
 		t.Error("handler 1 did not work")
 	}
 
-	if w.handlers["test"][1](nil); handlerResult {
+	if w.handlersFor("test")[1](nil); handlerResult {
 		t.Error("handler 2 did not work")
 	}
 }
@@ -111,11 +116,11 @@ func TestWebhook_DeleteHandlerByIndex(t *testing.T) {
 	if len(w.handlers["test"]) != 5 {
 		t.Error("invalid setup handlers")
 	}
-	if w.handlers["test"][2](nil); handlerExecute != 2 {
+	if w.handlersFor("test")[2](nil); handlerExecute != 2 {
 		t.Error("handler[2] did not work", handlerExecute)
 	}
 	w.DeleteHandlerByIndex("test", 2)
-	if w.handlers["test"][2](nil); handlerExecute == 2 {
+	if w.handlersFor("test")[2](nil); handlerExecute == 2 {
 		t.Error("handler[2 (before 3)] did not work")
 	}
 }
@@ -154,7 +159,7 @@ func TestWebhook_ServeHTTP(t *testing.T) {
 				Status:          StatusPaid,
 				Hash:            "exc1Hash",
 				Asset:           USDT,
-				Amount:          "1",
+				Amount:          MustParseAmount("1"),
 				PayUrl:          "/excHash",
 				CreatedAt:       time.Now(),
 				AllowComments:   false,
@@ -163,9 +168,9 @@ func TestWebhook_ServeHTTP(t *testing.T) {
 				PaidAnonymously: true,
 			},
 		})
-		var handled int
+		handled := make(chan int, 1)
 		w.Bind(UpdateInvoicePaid, func(update *WebhookUpdate) {
-			handled = update.Id
+			handled <- update.Id
 		})
 		req, _ := http.NewRequest("POST", server.URL, bytes.NewReader(data))
 		req.Header.Set(headerSignatureName, hex.EncodeToString(writeHmac(tokenHash, data)))
@@ -176,7 +181,12 @@ func TestWebhook_ServeHTTP(t *testing.T) {
 		if resp.StatusCode != http.StatusOK {
 			t.Errorf("resp.StatusCode(%d) != http.StatusOK", resp.StatusCode)
 		}
-		if handled != -1 {
+		select {
+		case id := <-handled:
+			if id != -1 {
+				t.Errorf("handler got update %d, expected -1", id)
+			}
+		case <-time.After(time.Second):
 			t.Errorf("handler did not work")
 		}
 
@@ -191,7 +201,7 @@ func TestWebhook_ServeHTTP(t *testing.T) {
 				Status:          StatusPaid,
 				Hash:            "exc2Hash",
 				Asset:           USDT,
-				Amount:          "1",
+				Amount:          MustParseAmount("1"),
 				PayUrl:          "/exc2Hash",
 				CreatedAt:       time.Now(),
 				AllowComments:   false,
@@ -238,3 +248,351 @@ func TestWebhook_ServeHTTP(t *testing.T) {
 		}
 	})
 }
+
+func TestSignWebhookBody(t *testing.T) {
+	const token = "5675:test_token"
+	body := []byte(`{"update_id":1}`)
+	signature := SignWebhookBody(token, body)
+	if signature != hex.EncodeToString(writeHmac(tokenHash, body)) {
+		t.Errorf("unexpected signature %s", signature)
+	}
+	if !VerifyWebhookSignature(token, body, signature) {
+		t.Error("valid signature not verified")
+	}
+	if VerifyWebhookSignature("1:other", body, signature) || VerifyWebhookSignature(token, body, "not hex") {
+		t.Error("invalid signature verified")
+	}
+}
+
+func TestNewSignedRequest(t *testing.T) {
+	handled := make(chan int, 1)
+	w := getWebhook(nil, func(_ *http.Request, err error) {
+		t.Error(err)
+	})
+	w.Bind(UpdateInvoicePaid, func(update *WebhookUpdate) {
+		handled <- update.Payload.Id
+	})
+	req, err := NewSignedRequest("5675:test_token", &WebhookUpdate{
+		Id:         1,
+		UpdateType: UpdateInvoicePaid,
+		Payload:    Invoice{Id: 42, Status: StatusPaid, Amount: NewAmount(1, 0)},
+	})
+	if err != nil {
+		t.Fatal(err)
+	}
+	rw := httptest.NewRecorder()
+	w.ServeHTTP(rw, req)
+	if rw.Code != http.StatusOK {
+		t.Fatalf("status(%d) != 200", rw.Code)
+	}
+	if id := <-handled; id != 42 {
+		t.Errorf("invoice id(%d) != 42", id)
+	}
+}
+
+func TestWebhook_DeleteHandler(t *testing.T) {
+	w := getWebhook(nil, nil)
+	var called []int
+	ids := make([]HandlerID, 3)
+	for i := range ids {
+		i := i
+		ids[i] = w.Bind("test", func(_ *WebhookUpdate) {
+			called = append(called, i)
+		})
+	}
+	if !w.DeleteHandler(ids[0]) || !w.DeleteHandler(ids[2]) {
+		t.Fatal("handler not found")
+	}
+	if w.DeleteHandler(ids[0]) {
+		t.Error("handler deleted twice")
+	}
+	for _, handler := range w.handlersFor("test") {
+		handler(nil)
+	}
+	if !reflect.DeepEqual(called, []int{1}) {
+		t.Errorf("called handlers %v, expected [1]", called)
+	}
+}
+
+func TestWebhook_Once(t *testing.T) {
+	w := getWebhook(nil, nil)
+	var calls int32
+	other := w.Bind(UpdateInvoicePaid, func(_ *WebhookUpdate) {})
+	w.Once(UpdateInvoicePaid, func(_ *WebhookUpdate) {
+		atomic.AddInt32(&calls, 1)
+	})
+	kept := w.Bind(UpdateInvoicePaid, func(_ *WebhookUpdate) {})
+	w.DeleteHandler(other)
+
+	handlers := w.handlersFor(UpdateInvoicePaid)
+	var wg sync.WaitGroup
+	for i := 0; i < 10; i++ {
+		wg.Add(1)
+		go func() {
+			defer wg.Done()
+			handlers[0](nil)
+		}()
+	}
+	wg.Wait()
+	if calls != 1 {
+		t.Errorf("once handler called %d times", calls)
+	}
+	if len(w.handlersFor(UpdateInvoicePaid)) != 1 || !w.DeleteHandler(kept) {
+		t.Error("once handler deleted other handler")
+	}
+}
+
+func TestWebhook_Concurrent(t *testing.T) {
+	const token = "5675:test_token"
+	w := NewWebhook(token, nil, nil)
+	var wg sync.WaitGroup
+	for i := 0; i < 20; i++ {
+		wg.Add(2)
+		go func() {
+			defer wg.Done()
+			id := w.Bind(UpdateInvoicePaid, func(_ *WebhookUpdate) {})
+			w.DeleteHandler(id)
+			w.Once(UpdateInvoicePaid, func(_ *WebhookUpdate) {})
+		}()
+		go func(i int) {
+			defer wg.Done()
+			req, _ := NewSignedRequest(token, &WebhookUpdate{Id: i, UpdateType: UpdateInvoicePaid})
+			w.ServeHTTP(httptest.NewRecorder(), req)
+		}(i)
+	}
+	wg.Wait()
+}
+
+func TestWebhook_Handle(t *testing.T) {
+	const token = "5675:test_token"
+	serve := func(w *Webhook) int {
+		req, _ := NewSignedRequest(token, &WebhookUpdate{Id: 1, UpdateType: UpdateInvoicePaid})
+		rw := httptest.NewRecorder()
+		w.ServeHTTP(rw, req)
+		return rw.Code
+	}
+	errDatabase := errors.New("database is down")
+
+	t.Run("ok", func(t *testing.T) {
+		w := NewWebhook(token, nil, nil)
+		var handled []int
+		w.Handle(UpdateInvoicePaid, func(ctx context.Context, update *WebhookUpdate) error {
+			handled = append(handled, update.Id)
+			return nil
+		})
+		if code := serve(w); code != http.StatusOK || len(handled) != 1 {
+			t.Errorf("status(%d), handled(%v)", code, handled)
+		}
+	})
+	t.Run("error", func(t *testing.T) {
+		var reported error
+		w := NewWebhook(token, nil, func(_ *http.Request, err error) {
+			reported = err
+		})
+		var next, async bool
+		w.Handle(UpdateInvoicePaid, func(ctx context.Context, update *WebhookUpdate) error {
+			return errDatabase
+		})
+		w.Handle(UpdateInvoicePaid, func(ctx context.Context, update *WebhookUpdate) error {
+			next = true
+			return nil
+		})
+		w.Bind(UpdateInvoicePaid, func(_ *WebhookUpdate) {
+			async = true
+		})
+		if code := serve(w); code != http.StatusInternalServerError {
+			t.Errorf("status(%d) != 500", code)
+		}
+		if !errors.Is(reported, errDatabase) {
+			t.Errorf("reported error(%v) != errDatabase", reported)
+		}
+		time.Sleep(10 * time.Millisecond)
+		if next || async {
+			t.Error("handlers called after error")
+		}
+	})
+	t.Run("status", func(t *testing.T) {
+		w := NewWebhook(token, nil, nil)
+		w.Handle(UpdateInvoicePaid, func(ctx context.Context, update *WebhookUpdate) error {
+			return &HandlerError{StatusCode: http.StatusConflict, Err: errDatabase}
+		})
+		if code := serve(w); code != http.StatusConflict {
+			t.Errorf("status(%d) != 409", code)
+		}
+	})
+	t.Run("timeout", func(t *testing.T) {
+		w := NewWebhook(token, nil, nil)
+		w.HandlerTimeout = 10 * time.Millisecond
+		w.Handle(UpdateInvoicePaid, func(ctx context.Context, update *WebhookUpdate) error {
+			<-ctx.Done()
+			time.Sleep(time.Second)
+			return nil
+		})
+		start := time.Now()
+		if code := serve(w); code != http.StatusServiceUnavailable {
+			t.Errorf("status(%d) != 503", code)
+		}
+		if elapsed := time.Since(start); elapsed > 500*time.Millisecond {
+			t.Errorf("response waited for handler %s", elapsed)
+		}
+	})
+}
+
+func TestWebhook_Freshness(t *testing.T) {
+	now := time.Date(2023, 5, 1, 12, 0, 0, 0, time.UTC)
+	var reported error
+	w := getWebhook(nil, func(_ *http.Request, err error) {
+		reported = err
+	})
+	w.MaxUpdateAge = time.Minute
+	w.ClockSkew = 5 * time.Second
+	w.now = func() time.Time { return now }
+
+	tests := []struct {
+		name        string
+		requestDate time.Time
+		wantCode    int
+	}{
+		{"fresh", now.Add(-30 * time.Second), http.StatusOK},
+		{"in skew", now.Add(-time.Minute - 3*time.Second), http.StatusOK},
+		{"stale", now.Add(-2 * time.Minute), http.StatusBadRequest},
+		{"future", now.Add(10 * time.Second), http.StatusBadRequest},
+		{"zero", time.Time{}, http.StatusBadRequest},
+	}
+	for i, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			reported = nil
+			code := serveUpdate(w, &WebhookUpdate{Id: i, UpdateType: UpdateInvoicePaid, RequestDate: tt.requestDate})
+			if code != tt.wantCode {
+				t.Errorf("status(%d) != %d", code, tt.wantCode)
+			}
+			if stale := errors.Is(reported, ErrorStaleUpdate); stale != (tt.wantCode != http.StatusOK) {
+				t.Errorf("unexpected error %v", reported)
+			}
+		})
+	}
+}
+
+func TestWebhook_Nonces(t *testing.T) {
+	var reported error
+	w := getWebhook(nil, func(_ *http.Request, err error) {
+		reported = err
+	})
+	w.Nonces = NewMemoryDedupStore(0)
+	fail := true
+	w.Handle(UpdateInvoicePaid, func(ctx context.Context, update *WebhookUpdate) error {
+		if fail {
+			return errors.New("temporary failure")
+		}
+		return nil
+	})
+
+	update := &WebhookUpdate{Id: 1, UpdateType: UpdateInvoicePaid, RequestDate: time.Now()}
+	if code := serveUpdate(w, update); code != http.StatusInternalServerError {
+		t.Fatalf("status(%d) != 500", code)
+	}
+	fail = false
+	if code := serveUpdate(w, update); code != http.StatusOK {
+		t.Fatalf("redelivery after failure: status(%d) != 200", code)
+	}
+	if code := serveUpdate(w, update); code != http.StatusBadRequest {
+		t.Errorf("replay: status(%d) != 400", code)
+	}
+	if !errors.Is(reported, ErrorReplayedUpdate) {
+		t.Errorf("err(%v) != ErrorReplayedUpdate", reported)
+	}
+
+	update.RequestDate = update.RequestDate.Add(time.Second)
+	if code := serveUpdate(w, update); code != http.StatusOK {
+		t.Errorf("new delivery: status(%d) != 200", code)
+	}
+}
+
+func TestWebhook_Shutdown(t *testing.T) {
+	for _, withDispatcher := range []bool{false, true} {
+		name := "goroutines"
+		if withDispatcher {
+			name = "dispatcher"
+		}
+		t.Run(name, func(t *testing.T) {
+			var reported error
+			w := getWebhook(nil, func(_ *http.Request, err error) {
+				reported = err
+			})
+			if withDispatcher {
+				w.Dispatcher = NewDispatcher(2, 2)
+				defer w.Dispatcher.Close()
+			}
+			release := make(chan struct{})
+			var handled int32
+			w.Bind(UpdateInvoicePaid, func(update *WebhookUpdate) {
+				<-release
+				atomic.AddInt32(&handled, 1)
+			})
+			for i := 1; i <= 2; i++ {
+				update := &WebhookUpdate{Id: i, UpdateType: UpdateInvoicePaid, Payload: Invoice{Id: i}}
+				if code := serveUpdate(w, update); code != http.StatusOK {
+					t.Fatalf("status(%d) != 200", code)
+				}
+			}
+
+			ctx, cancel := context.WithTimeout(context.Background(), 10*time.Millisecond)
+			defer cancel()
+			abandoned, err := w.Shutdown(ctx)
+			if abandoned != 2 || !errors.Is(err, context.DeadlineExceeded) {
+				t.Errorf("Shutdown() = %d, %v; want 2, deadline exceeded", abandoned, err)
+			}
+			if code := serveUpdate(w, &WebhookUpdate{Id: 3, UpdateType: UpdateInvoicePaid}); code != http.StatusServiceUnavailable {
+				t.Errorf("status(%d) != 503 after shutdown", code)
+			}
+			if !errors.Is(reported, ErrorShutdown) {
+				t.Errorf("err(%v) != ErrorShutdown", reported)
+			}
+
+			close(release)
+			abandoned, err = w.Shutdown(context.Background())
+			if abandoned != 0 || err != nil {
+				t.Errorf("Shutdown() = %d, %v; want 0, nil", abandoned, err)
+			}
+			if n := atomic.LoadInt32(&handled); n != 2 {
+				t.Errorf("handled(%d) != 2", n)
+			}
+		})
+	}
+}
+
+func TestWebhook_ShutdownSync(t *testing.T) {
+	for _, timeout := range []time.Duration{0, 5 * time.Millisecond} {
+		t.Run(fmt.Sprint("timeout ", timeout), func(t *testing.T) {
+			w := getWebhook(nil, nil)
+			w.HandlerTimeout = timeout
+			release := make(chan struct{})
+			started := make(chan struct{})
+			w.Handle(UpdateInvoicePaid, func(ctx context.Context, update *WebhookUpdate) error {
+				close(started)
+				<-release
+				return nil
+			})
+			served := make(chan int, 1)
+			go func() {
+				served <- serveUpdate(w, &WebhookUpdate{Id: 1, UpdateType: UpdateInvoicePaid})
+			}()
+			<-started
+			if timeout > 0 {
+				if code := <-served; code != http.StatusServiceUnavailable {
+					t.Fatalf("status(%d) != 503 after timeout", code)
+				}
+			}
+
+			ctx, cancel := context.WithTimeout(context.Background(), 10*time.Millisecond)
+			defer cancel()
+			if abandoned, err := w.Shutdown(ctx); abandoned != 1 || !errors.Is(err, context.DeadlineExceeded) {
+				t.Errorf("Shutdown() = %d, %v; want 1, deadline exceeded", abandoned, err)
+			}
+			close(release)
+			if abandoned, err := w.Shutdown(context.Background()); abandoned != 0 || err != nil {
+				t.Errorf("Shutdown() = %d, %v; want 0, nil", abandoned, err)
+			}
+		})
+	}
+}